@@ -1,5 +1,11 @@
 package config
 
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
 // ClientConfig define la configuración específica para cada cliente
 type ClientConfig struct {
 	MaxFileSize        int64    `json:"maxFileSize"`
@@ -8,6 +14,183 @@ type ClientConfig struct {
 	RequiresAuth       bool     `json:"requiresAuth"`
 	CompressionEnabled bool     `json:"compressionEnabled"`
 	Description        string   `json:"description"`
+	// MetadataSchema declara los campos personalizados del cliente (opcional)
+	MetadataSchema []CustomField `json:"metadataSchema,omitempty"`
+	// RateLimit define los límites de uso; los valores en cero usan DefaultRateLimit
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	// ClientCert habilita autenticación con certificado de cliente (mTLS) como
+	// alternativa al JWT para integraciones máquina a máquina (opcional)
+	ClientCert *ClientCertAuth `json:"clientCert,omitempty"`
+	// Cache define el Cache-Control de las descargas; nil usa la política por
+	// defecto según RequiresAuth
+	Cache *CachePolicy `json:"cache,omitempty"`
+	// MaxArchiveSize es el total de bytes permitido en una descarga masiva
+	// (ZIP/tar.gz); 0 usa DefaultMaxArchiveSize
+	MaxArchiveSize int64 `json:"maxArchiveSize,omitempty"`
+	// ImagePresets son las transformaciones de imagen que se aceptan sin firma:
+	// nombre -> parámetros ("w=400&h=400&fit=cover&format=webp"). Cualquier otra
+	// combinación requiere la firma con la clave de IMAGE_SIGNING_KEYS.
+	ImagePresets map[string]string `json:"imagePresets,omitempty"`
+	// StripGPS borra la ubicación GPS del EXIF de las fotos al subirlas o copiarlas
+	// al cliente (el resto del EXIF se conserva)
+	StripGPS bool `json:"stripGps,omitempty"`
+	// MalwareScan analiza las subidas y copias con el antivirus (CLAMD_ADDRESS);
+	// nil lo deshabilita
+	MalwareScan *ScanPolicy `json:"malwareScan,omitempty"`
+}
+
+// ScanPolicy define qué hacer cuando el antivirus no puede analizar un archivo
+type ScanPolicy struct {
+	// FailOpen acepta la subida si clamd no responde: el archivo queda pendiente
+	// y no se puede descargar hasta que un reintento lo analice. Sin FailOpen la
+	// subida se rechaza con 503.
+	FailOpen bool `json:"failOpen"`
+}
+
+// DefaultMaxArchiveSize es el límite de las descargas masivas si el cliente no define uno
+const DefaultMaxArchiveSize = 2 * 1024 * 1024 * 1024 // 2GB
+
+// GetMaxArchiveSize retorna el límite de descarga masiva del cliente
+func (c ClientConfig) GetMaxArchiveSize() int64 {
+	if c.MaxArchiveSize > 0 {
+		return c.MaxArchiveSize
+	}
+	return DefaultMaxArchiveSize
+}
+
+// CachePolicy define cómo pueden cachear las descargas navegadores y proxies.
+// El contenido de un fileId nunca cambia (una nueva versión es un archivo
+// nuevo), así que el riesgo de cachear es servir archivos ya eliminados
+// mientras dure MaxAgeSeconds.
+type CachePolicy struct {
+	// Public permite caches compartidos (proxies/CDN); se ignora si el cliente requiere auth
+	Public bool `json:"public"`
+	// MaxAgeSeconds es el tiempo sin revalidar; 0 obliga a revalidar con el ETag
+	// en cada uso, de modo que un archivo eliminado deja de servirse enseguida
+	MaxAgeSeconds int `json:"maxAgeSeconds"`
+	// Immutable evita revalidaciones al recargar la página durante MaxAgeSeconds
+	Immutable bool `json:"immutable"`
+	// NoStore prohíbe guardar las descargas en cualquier cache
+	NoStore bool `json:"noStore"`
+}
+
+// DefaultPublicCache es la política de los clientes sin autenticación
+var DefaultPublicCache = CachePolicy{Public: true, MaxAgeSeconds: 3600}
+
+// DefaultPrivateCache es la política de los clientes autenticados: solo el
+// navegador del usuario guarda la descarga y la revalida en cada uso
+var DefaultPrivateCache = CachePolicy{Public: false, MaxAgeSeconds: 0}
+
+// CacheControl retorna el header Cache-Control para las descargas del cliente
+func (c ClientConfig) CacheControl() string {
+	return c.cacheControl(-1)
+}
+
+// SignedCacheControl retorna el Cache-Control para un acceso con URL firmada:
+// el max-age no supera la vigencia de la firma para que el navegador no siga
+// mostrando el archivo después de que la URL expiró
+func (c ClientConfig) SignedCacheControl(remaining time.Duration) string {
+	return c.cacheControl(int(remaining.Seconds()))
+}
+
+// cacheControl arma el header limitando max-age a maxAgeCap segundos (-1 = sin límite)
+func (c ClientConfig) cacheControl(maxAgeCap int) string {
+	policy := DefaultPublicCache
+	if c.RequiresAuth {
+		policy = DefaultPrivateCache
+	}
+	if c.Cache != nil {
+		policy = *c.Cache
+	}
+
+	if policy.NoStore {
+		return "no-store"
+	}
+	if maxAgeCap >= 0 && policy.MaxAgeSeconds > maxAgeCap {
+		policy.MaxAgeSeconds = maxAgeCap
+	}
+
+	// Archivos que requieren auth nunca se guardan en caches compartidos
+	directives := []string{"private"}
+	if policy.Public && !c.RequiresAuth {
+		directives[0] = "public"
+	}
+
+	if policy.MaxAgeSeconds <= 0 {
+		return strings.Join(append(directives, "no-cache"), ", ")
+	}
+	directives = append(directives, "max-age="+strconv.Itoa(policy.MaxAgeSeconds))
+	if policy.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// ClientCertAuth define qué certificados de cliente se aceptan para un cliente.
+// Requiere TLS nativo (TLS_CERT_FILE); detrás de nginx el certificado no llega a la API.
+type ClientCertAuth struct {
+	// CAFile es el bundle PEM de CAs que firman los certificados del cliente
+	CAFile string `json:"caFile"`
+	// AllowedSubjects son los CN o DN completos aceptados ("CN=erp,O=Gaesa");
+	// vacío acepta cualquier certificado firmado por la CA
+	AllowedSubjects []string `json:"allowedSubjects,omitempty"`
+}
+
+// ClientCertCAFiles retorna los bundles de CA de todos los clientes con mTLS
+func ClientCertCAFiles() []string {
+	var files []string
+	for _, clientConfig := range ClientConfigs {
+		if clientConfig.ClientCert != nil && clientConfig.ClientCert.CAFile != "" {
+			files = append(files, clientConfig.ClientCert.CAFile)
+		}
+	}
+	return files
+}
+
+// RateLimitConfig define los presupuestos de rate limiting de un cliente.
+// Los límites por minuto se aplican por usuario autenticado (o por IP si no hay
+// usuario); ClientRequestsPerMinute es el total compartido por todo el cliente.
+type RateLimitConfig struct {
+	RequestsPerMinute       int   `json:"requestsPerMinute"`
+	UploadsPerMinute        int   `json:"uploadsPerMinute"`
+	UploadBytesPerSecond    int64 `json:"uploadBytesPerSecond"` // 0 = sin límite de ancho de banda
+	DownloadsPerMinute      int   `json:"downloadsPerMinute"`
+	ClientRequestsPerMinute int   `json:"clientRequestsPerMinute"` // 0 = sin límite global
+}
+
+// DefaultRateLimit se usa para los valores no configurados en un cliente
+var DefaultRateLimit = RateLimitConfig{
+	RequestsPerMinute:  600,
+	UploadsPerMinute:   60,
+	DownloadsPerMinute: 300,
+}
+
+// GetRateLimit retorna los límites de un cliente completando con los valores por defecto
+func (c ClientConfig) GetRateLimit() RateLimitConfig {
+	limits := c.RateLimit
+	if limits.RequestsPerMinute == 0 {
+		limits.RequestsPerMinute = DefaultRateLimit.RequestsPerMinute
+	}
+	if limits.UploadsPerMinute == 0 {
+		limits.UploadsPerMinute = DefaultRateLimit.UploadsPerMinute
+	}
+	if limits.UploadBytesPerSecond == 0 {
+		limits.UploadBytesPerSecond = DefaultRateLimit.UploadBytesPerSecond
+	}
+	if limits.DownloadsPerMinute == 0 {
+		limits.DownloadsPerMinute = DefaultRateLimit.DownloadsPerMinute
+	}
+	if limits.ClientRequestsPerMinute == 0 {
+		limits.ClientRequestsPerMinute = DefaultRateLimit.ClientRequestsPerMinute
+	}
+	return limits
+}
+
+// CustomField declara un campo de metadata personalizada y su tipo
+type CustomField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // string, number, boolean, date (YYYY-MM-DD)
+	Required bool   `json:"required"`
 }
 
 // ClientConfigs - Configuración basada en tu scripts/updateConfig.js existente
@@ -19,6 +202,15 @@ var ClientConfigs = map[string]ClientConfig{
 		RequiresAuth:       true,
 		CompressionEnabled: true,
 		Description:        "Acricolor - Archivos de catálogos y documentos",
+		MetadataSchema: []CustomField{
+			{Name: "temporada", Type: "string"},
+		},
+		// Imágenes de producto para la tienda web
+		ImagePresets: map[string]string{
+			"thumb": "w=200&h=200&fit=cover&format=webp",
+			"card":  "w=600&h=600&fit=cover&format=jpeg&q=80",
+			"zoom":  "w=1600&format=jpeg&q=85",
+		},
 	},
 	"lobeck": {
 		MaxFileSize:        100 * 1024 * 1024, // 100MB
@@ -27,6 +219,8 @@ var ClientConfigs = map[string]ClientConfig{
 		RequiresAuth:       true,
 		CompressionEnabled: true,
 		Description:        "Lobeck - Documentos técnicos y manuales",
+		// Acepta cualquier binario: se analiza, pero una caída de clamd no frena las subidas
+		MalwareScan: &ScanPolicy{FailOpen: true},
 	},
 	"gaesa": {
 		MaxFileSize:        200 * 1024 * 1024, // 200MB
@@ -35,6 +229,17 @@ var ClientConfigs = map[string]ClientConfig{
 		RequiresAuth:       true,
 		CompressionEnabled: true,
 		Description:        "Gaesa - Archivos de ingeniería y proyectos",
+		MetadataSchema: []CustomField{
+			{Name: "codigoProyecto", Type: "string"},
+			{Name: "numeroFactura", Type: "string"},
+		},
+		// Archivos que se comparten con terceros: sin análisis no se aceptan
+		MalwareScan: &ScanPolicy{FailOpen: false},
+		// Ejemplo de integración con certificado de cliente:
+		// ClientCert: &ClientCertAuth{
+		// 	CAFile:          "/app/ssl/gaesa-ca.pem",
+		// 	AllowedSubjects: []string{"CN=erp.gaesa.com.py"},
+		// },
 	},
 
 	"shared": {
@@ -44,6 +249,17 @@ var ClientConfigs = map[string]ClientConfig{
 		RequiresAuth:       false,
 		CompressionEnabled: false,
 		Description:        "Archivos compartidos - Sin autenticación requerida",
+		// Cliente público: límites más estrictos por IP
+		RateLimit: RateLimitConfig{
+			RequestsPerMinute:    120,
+			UploadsPerMinute:     10,
+			UploadBytesPerSecond: 2 * 1024 * 1024, // 2MB/s
+			DownloadsPerMinute:   120,
+		},
+		Cache:          &CachePolicy{Public: true, MaxAgeSeconds: 24 * 3600},
+		MaxArchiveSize: 200 * 1024 * 1024, // 200MB
+		// Las fotos son públicas: no exponer dónde se tomaron
+		StripGPS: true,
 	},
 }
 
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -16,6 +17,54 @@ type Config struct {
 	DefaultClient  string
 	AdminUser      string
 	AdminPassword  string
+	// IndexRefreshInterval es cada cuánto se revisan cambios en disco hechos fuera de la API
+	IndexRefreshInterval time.Duration
+	// LoginAttemptsPerMinute limita los intentos de login por IP
+	LoginAttemptsPerMinute int
+	// LoginMaxFailures es la cantidad de intentos fallidos antes de bloquear usuario/IP
+	LoginMaxFailures int
+	// LoginLockoutDuration es la duración del primer bloqueo (se duplica en cada bloqueo siguiente)
+	LoginLockoutDuration time.Duration
+	// LogFormat es el formato de los logs: "json" o "text"
+	LogFormat string
+	// LogLevel es el nivel mínimo de log: debug, info, warn o error
+	LogLevel string
+	// TrustedProxies son las IPs o redes (CIDR) cuyos X-Forwarded-For se respetan
+	TrustedProxies []string
+	// Timeouts del servidor HTTP. No hay timeout de lectura/escritura total para
+	// no cortar subidas y descargas grandes; se limita la espera de headers, la
+	// inactividad del body y las conexiones keep-alive ociosas.
+	ReadHeaderTimeout time.Duration
+	BodyIdleTimeout   time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout es cuánto se espera a las transferencias activas al apagar
+	ShutdownTimeout time.Duration
+	// TLS nativo (sin nginx): si TLSCertFile está configurado el servidor atiende HTTPS
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	// TLSClientAuth es el modo mTLS: none, optional o require
+	TLSClientAuth string
+	// TLSReloadInterval es cada cuánto se revisa si los certificados cambiaron
+	TLSReloadInterval time.Duration
+	// HTTPRedirectPort es el puerto HTTP que redirige a HTTPS (vacío = deshabilitado)
+	HTTPRedirectPort string
+	// TracesExporter es el destino de las trazas OpenTelemetry: otlp, stdout o none
+	TracesExporter string
+	// ServiceName identifica al servicio en las trazas
+	ServiceName string
+	// MetricsToken protege /metrics con un Bearer token (vacío = sin protección)
+	MetricsToken string
+	// ImageSigningKeys son las claves por cliente para firmar parámetros de
+	// transformación de imágenes fuera de los presets (cliente -> clave)
+	ImageSigningKeys map[string]string
+	// ClamdAddress es el daemon de ClamAV para los clientes con MalwareScan
+	// ("clamav:3310", "unix:/run/clamav/clamd.ctl"); vacío = sin antivirus
+	ClamdAddress string
+	// ClamdTimeout limita el análisis de un archivo
+	ClamdTimeout time.Duration
+	// ScanRetryInterval es cada cuánto se reintenta analizar los archivos pendientes
+	ScanRetryInterval time.Duration
 }
 
 func Load() *Config {
@@ -38,15 +87,39 @@ func Load() *Config {
 	}
 
 	return &Config{
-		Port:           getEnv("PORT", "3000"),
-		UploadDir:      getEnv("UPLOAD_DIR", "/app/uploads"),
-		MaxFileSize:    maxSize,
-		AllowedOrigins: origins,
-		JWTSecret:      getEnv("JWT_SECRET", "default_secret_change_in_production"),
-		Environment:    getEnv("GO_ENV", "development"),
-		DefaultClient:  getEnv("DEFAULT_CLIENT", "shared"),
-		AdminUser:      getEnv("USER", "admin"),
-		AdminPassword:  getEnv("PASSWORD", "admin123"),
+		Port:                   getEnv("PORT", "3000"),
+		UploadDir:              getEnv("UPLOAD_DIR", "/app/uploads"),
+		MaxFileSize:            maxSize,
+		AllowedOrigins:         origins,
+		JWTSecret:              getEnv("JWT_SECRET", "default_secret_change_in_production"),
+		Environment:            getEnv("GO_ENV", "development"),
+		DefaultClient:          getEnv("DEFAULT_CLIENT", "shared"),
+		AdminUser:              getEnv("USER", "admin"),
+		AdminPassword:          getEnv("PASSWORD", "admin123"),
+		IndexRefreshInterval:   getDurationEnv("INDEX_REFRESH_INTERVAL", 10*time.Second),
+		LoginAttemptsPerMinute: getIntEnv("LOGIN_RATE_LIMIT", 10),
+		LoginMaxFailures:       getIntEnv("LOGIN_MAX_FAILURES", 5),
+		LoginLockoutDuration:   getDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LogFormat:              getEnv("LOG_FORMAT", "json"),
+		LogLevel:               getEnv("LOG_LEVEL", "info"),
+		MetricsToken:           getEnv("METRICS_TOKEN", ""),
+		ReadHeaderTimeout:      getDurationEnv("READ_HEADER_TIMEOUT", 10*time.Second),
+		BodyIdleTimeout:        getDurationEnv("BODY_IDLE_TIMEOUT", 60*time.Second),
+		IdleTimeout:            getDurationEnv("IDLE_TIMEOUT", 120*time.Second),
+		ShutdownTimeout:        getDurationEnv("SHUTDOWN_TIMEOUT", 60*time.Second),
+		TLSCertFile:            getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:             getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:        getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:          getEnv("TLS_CLIENT_AUTH", "none"),
+		TLSReloadInterval:      getDurationEnv("TLS_RELOAD_INTERVAL", time.Minute),
+		HTTPRedirectPort:       getEnv("HTTP_REDIRECT_PORT", ""),
+		TracesExporter:         getEnv("OTEL_TRACES_EXPORTER", "none"),
+		ServiceName:            getEnv("OTEL_SERVICE_NAME", "file-server"),
+		TrustedProxies:         getListEnv("TRUSTED_PROXIES", []string{"127.0.0.1", "::1"}),
+		ImageSigningKeys:       getMapEnv("IMAGE_SIGNING_KEYS"),
+		ClamdAddress:           getEnv("CLAMD_ADDRESS", ""),
+		ClamdTimeout:           getDurationEnv("CLAMD_TIMEOUT", 2*time.Minute),
+		ScanRetryInterval:      getDurationEnv("SCAN_RETRY_INTERVAL", time.Minute),
 	}
 }
 
@@ -57,10 +130,54 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getMapEnv lee una lista "clave:valor,clave:valor"
+func getMapEnv(key string) map[string]string {
+	values := make(map[string]string)
+	for _, item := range getListEnv(key, nil) {
+		name, value, ok := strings.Cut(item, ":")
+		if ok && strings.TrimSpace(name) != "" && strings.TrimSpace(value) != "" {
+			values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
 func parseSize(size string) (int64, error) {
 	// Convertir "100MB" a bytes
 	size = strings.ToUpper(strings.TrimSpace(size))
-	
+
 	if strings.HasSuffix(size, "MB") {
 		size = strings.TrimSuffix(size, "MB")
 		if val, err := strconv.ParseInt(size, 10, 64); err == nil {
@@ -77,7 +194,7 @@ func parseSize(size string) (int64, error) {
 			return val * 1024, nil
 		}
 	}
-	
+
 	// Tratar como bytes si no tiene sufijo
 	return strconv.ParseInt(size, 10, 64)
-}
\ No newline at end of file
+}
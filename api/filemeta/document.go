@@ -0,0 +1,100 @@
+package filemeta
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"file-server-sofmar/models"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxXMLSize limita lo que se lee de cada XML de un documento Office
+const maxXMLSize = 4 * 1024 * 1024
+
+// extractPDF lee la cantidad de páginas y el título y autor del diccionario Info
+func extractPDF(path string, meta *models.ExtendedMetadata) (err error) {
+	// La librería entra en pánico con PDFs malformados
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("PDF no legible: %v", r)
+		}
+	}()
+
+	file, reader, err := pdf.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	meta.Pages = reader.NumPage()
+	info := reader.Trailer().Key("Info")
+	meta.Title = strings.TrimSpace(info.Key("Title").Text())
+	meta.Author = strings.TrimSpace(info.Key("Author").Text())
+	return nil
+}
+
+// extractOffice lee las propiedades de documentos Office Open XML (docx, xlsx,
+// pptx): título y autor de docProps/core.xml, páginas o diapositivas de
+// docProps/app.xml y, en planillas, los nombres de las hojas
+func extractOffice(path, ext string, meta *models.ExtendedMetadata) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var core struct {
+		Title   string `xml:"title"`
+		Creator string `xml:"creator"`
+	}
+	if err := readZipXML(&zr.Reader, "docProps/core.xml", &core); err == nil {
+		meta.Title = strings.TrimSpace(core.Title)
+		meta.Author = strings.TrimSpace(core.Creator)
+	}
+
+	var app struct {
+		Pages  string `xml:"Pages"`
+		Slides string `xml:"Slides"`
+	}
+	if err := readZipXML(&zr.Reader, "docProps/app.xml", &app); err == nil {
+		if pages, err := strconv.Atoi(strings.TrimSpace(app.Pages + app.Slides)); err == nil {
+			meta.Pages = pages
+		}
+	}
+
+	if ext == ".xlsx" {
+		var workbook struct {
+			Sheets []struct {
+				Name string `xml:"name,attr"`
+			} `xml:"sheets>sheet"`
+		}
+		if err := readZipXML(&zr.Reader, "xl/workbook.xml", &workbook); err != nil {
+			return err
+		}
+		for _, sheet := range workbook.Sheets {
+			meta.Sheets = append(meta.Sheets, sheet.Name)
+		}
+	}
+	return nil
+}
+
+// readZipXML decodifica un XML dentro del ZIP
+func readZipXML(zr *zip.Reader, name string, v interface{}) error {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return xml.NewDecoder(io.LimitReader(rc, maxXMLSize)).Decode(v)
+	}
+	return fmt.Errorf("%s no encontrado", name)
+}
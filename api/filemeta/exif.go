@@ -0,0 +1,409 @@
+package filemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"file-server-sofmar/models"
+)
+
+// maxExifSize limita el bloque EXIF que se lee (en JPEG no puede superar 64KB)
+const maxExifSize = 1 << 20
+
+// Tags EXIF usados
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+	tagGPSAltitudeRef   = 0x0005
+	tagGPSAltitude      = 0x0006
+)
+
+// exifBlock es la ubicación del bloque TIFF con el EXIF dentro del archivo
+type exifBlock struct {
+	offset int64
+	size   int64
+	// pngCRC es la posición del CRC del chunk eXIf (-1 si no es PNG)
+	pngCRC int64
+}
+
+// within indica si el bloque (y el CRC del chunk PNG) está dentro de un archivo de fileSize bytes
+func (b exifBlock) within(fileSize int64) bool {
+	if b.offset < 0 || b.size < 0 || b.offset+b.size > fileSize {
+		return false
+	}
+	return b.pngCRC < 0 || b.pngCRC+4 <= fileSize
+}
+
+// findExif busca el bloque EXIF en un JPEG, PNG o WebP recorriendo su estructura
+func findExif(file *os.File, ext string) (exifBlock, bool) {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return findJPEGExif(file)
+	case ".png":
+		return findPNGExif(file)
+	case ".webp":
+		return findWebPExif(file)
+	}
+	return exifBlock{}, false
+}
+
+// findJPEGExif busca el segmento APP1 "Exif" antes de los datos de la imagen
+func findJPEGExif(file *os.File) (exifBlock, bool) {
+	header := make([]byte, 10)
+	offset := int64(2)
+	for {
+		if _, err := file.ReadAt(header[:4], offset); err != nil || header[0] != 0xFF {
+			return exifBlock{}, false
+		}
+		marker := header[1]
+		if marker == 0xDA || marker == 0xD9 { // inicio de datos o fin de imagen
+			return exifBlock{}, false
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:4]))
+		if length < 2 {
+			return exifBlock{}, false
+		}
+		if marker == 0xE1 && length > 8 {
+			if _, err := file.ReadAt(header[4:10], offset+4); err == nil && string(header[4:10]) == "Exif\x00\x00" {
+				return exifBlock{offset: offset + 10, size: length - 8, pngCRC: -1}, true
+			}
+		}
+		offset += 2 + length
+	}
+}
+
+// findPNGExif busca el chunk eXIf
+func findPNGExif(file *os.File) (exifBlock, bool) {
+	header := make([]byte, 8)
+	offset := int64(8)
+	for {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			return exifBlock{}, false
+		}
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		switch string(header[4:8]) {
+		case "eXIf":
+			return exifBlock{offset: offset + 8, size: length, pngCRC: offset + 8 + length}, true
+		case "IEND":
+			return exifBlock{}, false
+		}
+		offset += 12 + length
+	}
+}
+
+// findWebPExif busca el chunk EXIF del contenedor RIFF
+func findWebPExif(file *os.File) (exifBlock, bool) {
+	header := make([]byte, 12)
+	if _, err := file.ReadAt(header, 0); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return exifBlock{}, false
+	}
+	offset := int64(12)
+	for {
+		if _, err := file.ReadAt(header[:8], offset); err != nil {
+			return exifBlock{}, false
+		}
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		if string(header[0:4]) == "EXIF" {
+			block := exifBlock{offset: offset + 8, size: size, pngCRC: -1}
+			// Algunos programas mantienen el prefijo "Exif\0\0" de JPEG
+			prefix := make([]byte, 6)
+			if _, err := file.ReadAt(prefix, block.offset); err == nil && string(prefix) == "Exif\x00\x00" {
+				block.offset += 6
+				block.size -= 6
+			}
+			return block, true
+		}
+		offset += 8 + size + size&1
+	}
+}
+
+// readExif lee el bloque TIFF del EXIF de una imagen
+func readExif(file *os.File, ext string) (*tiff, exifBlock, bool) {
+	block, ok := findExif(file, ext)
+	if !ok || block.size < 8 || block.size > maxExifSize {
+		return nil, block, false
+	}
+	// Un bloque que declara más bytes de los que tiene el archivo da EOF o
+	// ErrUnexpectedEOF y se trata como EXIF mal formado
+	data := make([]byte, block.size)
+	if _, err := io.ReadFull(io.NewSectionReader(file, block.offset, block.size), data); err != nil {
+		return nil, block, false
+	}
+	t, ok := newTIFF(data)
+	return t, block, ok
+}
+
+// extractExif completa la metadata con los datos de cámara, fecha y ubicación
+func extractExif(path, ext string, meta *models.ExtendedMetadata) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	t, _, ok := readExif(file, ext)
+	if !ok {
+		return
+	}
+	ifd0, ok := t.ifd(t.firstIFD())
+	if !ok {
+		return
+	}
+
+	meta.CameraMake = t.str(ifd0[tagMake])
+	meta.CameraModel = t.str(ifd0[tagModel])
+	if orientation, ok := t.uint(ifd0[tagOrientation]); ok && orientation >= 1 && orientation <= 8 {
+		meta.Orientation = int(orientation)
+	}
+
+	taken := t.str(ifd0[tagDateTime])
+	if offset, ok := t.uint(ifd0[tagExifIFD]); ok {
+		if exifIFD, ok := t.ifd(int(offset)); ok {
+			if original := t.str(exifIFD[tagDateTimeOriginal]); original != "" {
+				taken = original
+			}
+		}
+	}
+	if at, err := time.Parse("2006:01:02 15:04:05", taken); err == nil {
+		meta.TakenAt = &at
+	}
+
+	if offset, ok := t.uint(ifd0[tagGPSIFD]); ok {
+		if gps, ok := t.ifd(int(offset)); ok {
+			meta.GPS = gpsCoordinates(t, gps)
+		}
+	}
+}
+
+// gpsCoordinates convierte los grados/minutos/segundos del EXIF a decimales
+func gpsCoordinates(t *tiff, gps map[uint16]ifdEntry) *models.GPSCoordinates {
+	lat, okLat := t.degrees(gps[tagGPSLatitude])
+	lon, okLon := t.degrees(gps[tagGPSLongitude])
+	if !okLat || !okLon {
+		return nil
+	}
+	if t.str(gps[tagGPSLatitudeRef]) == "S" {
+		lat = -lat
+	}
+	if t.str(gps[tagGPSLongitudeRef]) == "W" {
+		lon = -lon
+	}
+
+	coords := &models.GPSCoordinates{Latitude: lat, Longitude: lon}
+	if alt, ok := t.rational(gps[tagGPSAltitude], 0); ok {
+		if ref, ok := t.uint(gps[tagGPSAltitudeRef]); ok && ref == 1 {
+			alt = -alt // bajo el nivel del mar
+		}
+		coords.Altitude = &alt
+	}
+	return coords
+}
+
+// StripGPS borra la ubicación del EXIF de una imagen JPEG, PNG o WebP sin
+// recomprimirla: se vacía el IFD de GPS y se ponen en cero sus valores, así el
+// archivo conserva el tamaño y el resto del EXIF. Retorna true si se modificó.
+func StripGPS(path, ext string) (bool, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	t, block, ok := readExif(file, ext)
+	if !ok {
+		return false, nil
+	}
+	ifd0, ok := t.ifd(t.firstIFD())
+	if !ok {
+		return false, nil
+	}
+	offset, ok := t.uint(ifd0[tagGPSIFD])
+	if !ok {
+		return false, nil
+	}
+	gps, ok := t.ifd(int(offset))
+	if !ok || len(gps) == 0 {
+		return false, nil
+	}
+
+	// Todo lo que se reescribe tiene que estar dentro del archivo
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !block.within(info.Size()) {
+		return false, nil
+	}
+
+	for _, entry := range gps {
+		t.zero(entry.valuePos, entry.valuePos+entry.size())
+	}
+	// Se usa la cantidad declarada en el IFD: gps no incluye entradas inválidas ni repetidas
+	count := int(t.order.Uint16(t.data[offset:]))
+	t.zero(int(offset), int(offset)+2+12*count+4)
+
+	if _, err := file.WriteAt(t.data, block.offset); err != nil {
+		return true, err
+	}
+	if block.pngCRC >= 0 {
+		crc := crc32.NewIEEE()
+		crc.Write([]byte("eXIf"))
+		crc.Write(t.data)
+		sum := make([]byte, 4)
+		binary.BigEndian.PutUint32(sum, crc.Sum32())
+		if _, err := file.WriteAt(sum, block.pngCRC); err != nil {
+			return true, err
+		}
+	}
+	return true, file.Sync()
+}
+
+// tiff es un bloque EXIF (estructura TIFF) en memoria
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry es una entrada de un IFD; valuePos es la posición de su valor en data
+type ifdEntry struct {
+	typ      uint16
+	count    uint32
+	valuePos int
+}
+
+// typeSizes es el tamaño en bytes de cada tipo TIFF
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+func (e ifdEntry) size() int {
+	return typeSizes[e.typ] * int(e.count)
+}
+
+func newTIFF(data []byte) (*tiff, bool) {
+	t := &tiff{data: data}
+	switch {
+	case bytes.HasPrefix(data, []byte("II*\x00")):
+		t.order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte("MM\x00*")):
+		t.order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	return t, true
+}
+
+func (t *tiff) firstIFD() int {
+	return int(t.order.Uint32(t.data[4:8]))
+}
+
+// ifd lee las entradas de un IFD validando que todo quede dentro del bloque
+func (t *tiff) ifd(offset int) (map[uint16]ifdEntry, bool) {
+	if offset < 8 || offset+2 > len(t.data) {
+		return nil, false
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	if offset+2+12*count > len(t.data) {
+		return nil, false
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		pos := offset + 2 + 12*i
+		entry := ifdEntry{
+			typ:      t.order.Uint16(t.data[pos+2:]),
+			count:    t.order.Uint32(t.data[pos+4:]),
+			valuePos: pos + 8,
+		}
+		size := entry.size()
+		if size == 0 || size > len(t.data) {
+			continue
+		}
+		if size > 4 {
+			entry.valuePos = int(t.order.Uint32(t.data[pos+8:]))
+		}
+		if entry.valuePos < 0 || entry.valuePos+size > len(t.data) {
+			continue
+		}
+		entries[t.order.Uint16(t.data[pos:])] = entry
+	}
+	return entries, true
+}
+
+// str retorna un valor ASCII sin el terminador ni espacios de relleno
+func (t *tiff) str(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	value := t.data[e.valuePos : e.valuePos+int(e.count)]
+	if i := bytes.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(string(value), ""))
+}
+
+// uint retorna el primer valor de una entrada BYTE, SHORT o LONG
+func (t *tiff) uint(e ifdEntry) (uint32, bool) {
+	switch e.typ {
+	case 1:
+		return uint32(t.data[e.valuePos]), true
+	case 3:
+		return uint32(t.order.Uint16(t.data[e.valuePos:])), true
+	case 4:
+		return t.order.Uint32(t.data[e.valuePos:]), true
+	}
+	return 0, false
+}
+
+// rational retorna el valor i de una entrada RATIONAL
+func (t *tiff) rational(e ifdEntry, i int) (float64, bool) {
+	if e.typ != 5 || i >= int(e.count) {
+		return 0, false
+	}
+	pos := e.valuePos + 8*i
+	num := t.order.Uint32(t.data[pos:])
+	den := t.order.Uint32(t.data[pos+4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// degrees convierte grados, minutos y segundos a grados decimales
+func (t *tiff) degrees(e ifdEntry) (float64, bool) {
+	if e.count < 3 {
+		return 0, false
+	}
+	d, ok1 := t.rational(e, 0)
+	m, ok2 := t.rational(e, 1)
+	s, ok3 := t.rational(e, 2)
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	return d + m/60 + s/3600, true
+}
+
+// zero pone en cero un rango del bloque
+func (t *tiff) zero(from, to int) {
+	if to > len(t.data) {
+		to = len(t.data)
+	}
+	if from < 0 || from >= to {
+		return
+	}
+	for i := from; i < to; i++ {
+		t.data[i] = 0
+	}
+}
@@ -0,0 +1,122 @@
+package filemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-server-sofmar/models"
+)
+
+// gpsTIFF arma un bloque EXIF little-endian con IFD0 apuntando a un IFD de GPS
+// con latitud 10° 20' 30" N
+func gpsTIFF() []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	entry := func(tag, typ uint16, count, value uint32) {
+		binary.Write(&b, le, tag)
+		binary.Write(&b, le, typ)
+		binary.Write(&b, le, count)
+		binary.Write(&b, le, value)
+	}
+
+	b.WriteString("II*\x00")
+	binary.Write(&b, le, uint32(8))
+	// IFD0 (offset 8): solo el puntero al IFD de GPS
+	binary.Write(&b, le, uint16(1))
+	entry(tagGPSIFD, 4, 1, 26)
+	binary.Write(&b, le, uint32(0))
+	// IFD de GPS (offset 26)
+	binary.Write(&b, le, uint16(4))
+	entry(tagGPSLatitudeRef, 2, 2, 'N')
+	entry(tagGPSLatitude, 5, 3, 80)
+	entry(tagGPSLongitudeRef, 2, 2, 'E')
+	entry(tagGPSLongitude, 5, 3, 80)
+	binary.Write(&b, le, uint32(0))
+	// Valores RATIONAL (offset 80)
+	for _, v := range []uint32{10, 1, 20, 1, 30, 1} {
+		binary.Write(&b, le, v)
+	}
+	return b.Bytes()
+}
+
+func jpegWithExif(tiff []byte) []byte {
+	var b bytes.Buffer
+	b.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&b, binary.BigEndian, uint16(2+6+len(tiff)))
+	b.WriteString("Exif\x00\x00")
+	b.Write(tiff)
+	b.Write([]byte{0xFF, 0xD9})
+	return b.Bytes()
+}
+
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStripGPS(t *testing.T) {
+	path := writeTemp(t, "foto.jpg", jpegWithExif(gpsTIFF()))
+
+	var before models.ExtendedMetadata
+	extractExif(path, ".jpg", &before)
+	if before.GPS == nil {
+		t.Fatal("extractExif() no encontró la ubicación de prueba")
+	}
+
+	stripped, err := StripGPS(path, ".jpg")
+	if err != nil || !stripped {
+		t.Fatalf("StripGPS() = %v, %v; esperado true, nil", stripped, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != len(jpegWithExif(gpsTIFF())) {
+		t.Errorf("StripGPS() cambió el tamaño del archivo a %d bytes", len(data))
+	}
+	var after models.ExtendedMetadata
+	extractExif(path, ".jpg", &after)
+	if after.GPS != nil {
+		t.Errorf("la ubicación sigue en el EXIF: %+v", after.GPS)
+	}
+}
+
+func TestStripGPSMalformed(t *testing.T) {
+	tiff := gpsTIFF()
+
+	// PNG con el chunk eXIf completo pero sin su CRC
+	var png bytes.Buffer
+	png.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&png, binary.BigEndian, uint32(len(tiff)))
+	png.WriteString("eXIf")
+	png.Write(tiff)
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+	}{
+		{"JPEG con el bloque EXIF cortado", "foto.jpg", jpegWithExif(tiff)[:4+2+6+len(tiff)-10]},
+		{"PNG sin CRC del chunk eXIf", "foto.png", png.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTemp(t, tt.file, tt.data)
+
+			stripped, err := StripGPS(path, filepath.Ext(tt.file))
+			if err != nil || stripped {
+				t.Fatalf("StripGPS() = %v, %v; esperado false, nil", stripped, err)
+			}
+			data, _ := os.ReadFile(path)
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("StripGPS() modificó un archivo mal formado (%d bytes, antes %d)", len(data), len(tt.data))
+			}
+		})
+	}
+}
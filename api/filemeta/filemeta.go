@@ -0,0 +1,113 @@
+package filemeta
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif" // decoders registrados para image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+
+	"file-server-sofmar/models"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Supported indica si hay extractor de metadata embebida para la extensión
+func Supported(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp",
+		".pdf", ".docx", ".xlsx", ".pptx",
+		".mp4", ".m4a", ".m4v", ".mov", ".3gp", ".wav", ".flac", ".mp3", ".mkv", ".mka", ".webm":
+		return true
+	}
+	return false
+}
+
+// Extract lee la metadata embebida de un archivo según su extensión. Retorna
+// nil sin error si el formato no tiene extractor o el archivo no trae datos.
+func Extract(path, ext string) (*models.ExtendedMetadata, error) {
+	ext = strings.ToLower(ext)
+	if !Supported(ext) {
+		return nil, nil
+	}
+
+	meta := &models.ExtendedMetadata{}
+	var err error
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		err = extractImage(path, ext, meta)
+	case ".pdf":
+		err = extractPDF(path, meta)
+	case ".docx", ".xlsx", ".pptx":
+		err = extractOffice(path, ext, meta)
+	default:
+		meta.DurationSeconds, err = mediaDuration(path, ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if isEmpty(meta) {
+		return nil, nil
+	}
+	return meta, nil
+}
+
+// extractImage lee las dimensiones y, si tiene, el EXIF de una imagen
+func extractImage(path, ext string, meta *models.ExtendedMetadata) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return fmt.Errorf("imagen no soportada: %v", err)
+	}
+	meta.Width = cfg.Width
+	meta.Height = cfg.Height
+
+	extractExif(path, ext, meta)
+	return nil
+}
+
+// mediaDuration retorna la duración en segundos de un archivo de audio o video
+func mediaDuration(path, ext string) (float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	switch ext {
+	case ".wav":
+		return wavDuration(file)
+	case ".flac":
+		return flacDuration(file)
+	case ".mp3":
+		return mp3Duration(file, stat.Size())
+	case ".mkv", ".mka", ".webm":
+		if !isEBML(file) {
+			return 0, fmt.Errorf("contenedor Matroska inválido")
+		}
+		return matroskaDuration(file)
+	default:
+		return mp4Duration(file, stat.Size())
+	}
+}
+
+// isEmpty indica si no se extrajo ningún dato
+func isEmpty(meta *models.ExtendedMetadata) bool {
+	return meta.Width == 0 && meta.Height == 0 && meta.Orientation == 0 &&
+		meta.CameraMake == "" && meta.CameraModel == "" && meta.TakenAt == nil && meta.GPS == nil &&
+		meta.Pages == 0 && meta.Title == "" && meta.Author == "" && len(meta.Sheets) == 0 &&
+		meta.DurationSeconds == 0
+}
@@ -0,0 +1,340 @@
+package filemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Duración de audio y video leyendo solo los encabezados del contenedor
+
+// mp4Duration lee la duración del box mvhd (MP4, MOV, M4A, 3GP)
+func mp4Duration(file *os.File, size int64) (float64, error) {
+	moov, moovSize, ok := findBox(file, 0, size, "moov")
+	if !ok {
+		return 0, fmt.Errorf("box moov no encontrado")
+	}
+	mvhd, _, ok := findBox(file, moov, moov+moovSize, "mvhd")
+	if !ok {
+		return 0, fmt.Errorf("box mvhd no encontrado")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := file.ReadAt(buf, mvhd); err != nil {
+		return 0, err
+	}
+	var timescale uint32
+	var duration uint64
+	if buf[0] == 1 { // versión 1: fechas y duración de 64 bits
+		timescale = binary.BigEndian.Uint32(buf[20:24])
+		duration = binary.BigEndian.Uint64(buf[24:32])
+	} else {
+		timescale = binary.BigEndian.Uint32(buf[12:16])
+		duration = uint64(binary.BigEndian.Uint32(buf[16:20]))
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("timescale inválido")
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// findBox busca un box ISO BMFF entre start y end; retorna la posición y el
+// tamaño de su contenido
+func findBox(file *os.File, start, end int64, boxType string) (int64, int64, bool) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := file.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, false
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0: // hasta el final
+			size = end - offset
+		case 1: // tamaño de 64 bits
+			if _, err := file.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, false
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return 0, 0, false
+		}
+		if string(header[4:8]) == boxType {
+			return offset + headerSize, size - headerSize, true
+		}
+		offset += size
+	}
+	return 0, 0, false
+}
+
+// wavDuration divide el tamaño del chunk data por los bytes por segundo de fmt
+func wavDuration(file *os.File) (float64, error) {
+	header := make([]byte, 12)
+	if _, err := file.ReadAt(header, 0); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("WAV inválido")
+	}
+
+	var byteRate uint32
+	for offset := int64(12); ; {
+		if _, err := file.ReadAt(header[:8], offset); err != nil {
+			return 0, fmt.Errorf("chunk data no encontrado")
+		}
+		size := binary.LittleEndian.Uint32(header[4:8])
+		switch string(header[0:4]) {
+		case "fmt ":
+			fmtChunk := make([]byte, 12)
+			if _, err := file.ReadAt(fmtChunk, offset+8); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("chunk fmt inválido")
+			}
+			return float64(size) / float64(byteRate), nil
+		}
+		offset += 8 + int64(size) + int64(size&1)
+	}
+}
+
+// flacDuration lee las muestras totales y la frecuencia del bloque STREAMINFO
+func flacDuration(file *os.File) (float64, error) {
+	buf := make([]byte, 26)
+	if _, err := file.ReadAt(buf, 0); err != nil || string(buf[0:4]) != "fLaC" || buf[4]&0x7F != 0 {
+		return 0, fmt.Errorf("FLAC inválido")
+	}
+	info := buf[8:]
+	sampleRate := uint32(info[10])<<12 | uint32(info[11])<<4 | uint32(info[12])>>4
+	totalSamples := uint64(info[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 || totalSamples == 0 {
+		return 0, fmt.Errorf("duración no informada")
+	}
+	return float64(totalSamples) / float64(sampleRate), nil
+}
+
+// Tablas de MPEG audio: bitrate en kbps por [versión MPEG-1][capa][índice]
+var mp3Bitrates = [2][3][16]int{
+	{ // MPEG-1
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{ // MPEG-2 y 2.5
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+var mp3SampleRates = [3][3]int{
+	{44100, 48000, 32000}, // MPEG-1
+	{22050, 24000, 16000}, // MPEG-2
+	{11025, 12000, 8000},  // MPEG-2.5
+}
+
+// mp3Duration usa el encabezado Xing/Info o VBRI si existe (VBR); si no, estima
+// por tamaño y bitrate del primer frame (CBR)
+func mp3Duration(file *os.File, size int64) (float64, error) {
+	// Saltar el tag ID3v2
+	start := int64(0)
+	id3 := make([]byte, 10)
+	if _, err := file.ReadAt(id3, 0); err == nil && string(id3[0:3]) == "ID3" {
+		start = 10 + int64(id3[6]&0x7F)<<21 | int64(id3[7]&0x7F)<<14 | int64(id3[8]&0x7F)<<7 | int64(id3[9]&0x7F)
+		if id3[5]&0x10 != 0 { // footer
+			start += 10
+		}
+	}
+
+	buf := make([]byte, 4096)
+	n, err := file.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	buf = buf[:n]
+
+	// Primer frame válido
+	pos := -1
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] == 0xFF && buf[i+1]&0xE0 == 0xE0 {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("frame MPEG no encontrado")
+	}
+	header := buf[pos:]
+
+	versionBits := header[1] >> 3 & 0x03 // 3 = MPEG-1, 2 = MPEG-2, 0 = MPEG-2.5
+	layerBits := header[1] >> 1 & 0x03   // 3 = capa I, 2 = capa II, 1 = capa III
+	bitrateIndex := header[2] >> 4
+	rateIndex := header[2] >> 2 & 0x03
+	if versionBits == 1 || layerBits == 0 || bitrateIndex == 0x0F || rateIndex == 3 {
+		return 0, fmt.Errorf("encabezado MPEG inválido")
+	}
+
+	version := map[byte]int{3: 0, 2: 1, 0: 2}[versionBits]
+	layer := 3 - int(layerBits) // 0 = capa I
+	sampleRate := mp3SampleRates[version][rateIndex]
+	bitrate := mp3Bitrates[min(version, 1)][layer][bitrateIndex] * 1000
+	mono := header[3]>>6 == 3
+
+	samplesPerFrame := 1152
+	switch {
+	case layer == 0:
+		samplesPerFrame = 384
+	case layer == 2 && version > 0:
+		samplesPerFrame = 576
+	}
+
+	// Xing/Info: después de la side info de capa III
+	sideInfo := 32
+	switch {
+	case version == 0 && mono:
+		sideInfo = 17
+	case version > 0 && !mono:
+		sideInfo = 17
+	case version > 0 && mono:
+		sideInfo = 9
+	}
+	if xing := 4 + sideInfo; xing+12 <= len(header) {
+		tag := string(header[xing : xing+4])
+		if (tag == "Xing" || tag == "Info") && header[xing+7]&0x01 != 0 {
+			frames := binary.BigEndian.Uint32(header[xing+8 : xing+12])
+			return float64(frames) * float64(samplesPerFrame) / float64(sampleRate), nil
+		}
+	}
+	if vbri := 4 + 32; vbri+18 <= len(header) && string(header[vbri:vbri+4]) == "VBRI" {
+		frames := binary.BigEndian.Uint32(header[vbri+14 : vbri+18])
+		return float64(frames) * float64(samplesPerFrame) / float64(sampleRate), nil
+	}
+
+	if bitrate == 0 {
+		return 0, fmt.Errorf("bitrate libre no soportado")
+	}
+	audioSize := size - start - int64(pos)
+	tail := make([]byte, 3)
+	if _, err := file.ReadAt(tail, size-128); err == nil && string(tail) == "TAG" { // ID3v1
+		audioSize -= 128
+	}
+	return float64(audioSize) * 8 / float64(bitrate), nil
+}
+
+// IDs EBML de Matroska/WebM
+const (
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+)
+
+// maxEBMLScan es cuánto del inicio del archivo se recorre buscando Info
+const maxEBMLScan = 1 << 20
+
+// matroskaDuration lee Duration y TimecodeScale del elemento Info (MKV, WebM)
+func matroskaDuration(file *os.File) (float64, error) {
+	buf := make([]byte, maxEBMLScan)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	buf = buf[:n]
+
+	segment, ok := ebmlChild(buf, ebmlSegment)
+	if !ok {
+		return 0, fmt.Errorf("segmento Matroska no encontrado")
+	}
+	info, ok := ebmlChild(segment, ebmlInfo)
+	if !ok {
+		return 0, fmt.Errorf("elemento Info no encontrado")
+	}
+
+	scale := uint64(1000000) // nanosegundos por unidad (default de la especificación)
+	if value, ok := ebmlChild(info, ebmlTimecodeScale); ok && len(value) <= 8 {
+		scale = 0
+		for _, b := range value {
+			scale = scale<<8 | uint64(b)
+		}
+	}
+	value, ok := ebmlChild(info, ebmlDuration)
+	if !ok {
+		return 0, fmt.Errorf("duración no informada")
+	}
+	var duration float64
+	switch len(value) {
+	case 4:
+		duration = float64(math.Float32frombits(binary.BigEndian.Uint32(value)))
+	case 8:
+		duration = math.Float64frombits(binary.BigEndian.Uint64(value))
+	default:
+		return 0, fmt.Errorf("duración inválida")
+	}
+	return duration * float64(scale) / 1e9, nil
+}
+
+// ebmlChild busca un elemento por ID entre los hijos directos de data y retorna
+// su contenido (recortado a lo leído si el tamaño es desconocido o excede data)
+func ebmlChild(data []byte, id uint32) ([]byte, bool) {
+	for pos := 0; pos < len(data); {
+		elemID, idLen := ebmlVarInt(data[pos:], false)
+		if idLen == 0 {
+			return nil, false
+		}
+		size, sizeLen := ebmlVarInt(data[pos+idLen:], true)
+		if sizeLen == 0 {
+			return nil, false
+		}
+		start := pos + idLen + sizeLen
+		end := len(data)
+		if size >= 0 && start+int(size) < end {
+			end = start + int(size)
+		}
+		if uint32(elemID) == id {
+			return data[start:end], true
+		}
+		if size < 0 {
+			return nil, false
+		}
+		pos = end
+	}
+	return nil, false
+}
+
+// ebmlVarInt decodifica un entero de largo variable. Los IDs conservan el
+// marcador de largo; en los tamaños se quita y "todos unos" es desconocido (-1).
+func ebmlVarInt(data []byte, isSize bool) (int64, int) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0
+	}
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || length > len(data) {
+		return 0, 0
+	}
+
+	value := int64(data[0])
+	if isSize {
+		value &= int64(0xFF >> length)
+	}
+	for i := 1; i < length; i++ {
+		value = value<<8 | int64(data[i])
+	}
+	if isSize && value == int64(1)<<(7*length)-1 {
+		return -1, length
+	}
+	return value, length
+}
+
+// isEBML indica si el archivo empieza con el encabezado EBML
+func isEBML(file *os.File) bool {
+	magic := make([]byte, 4)
+	_, err := file.ReadAt(magic, 0)
+	return err == nil && bytes.Equal(magic, []byte{0x1A, 0x45, 0xDF, 0xA3})
+}
@@ -0,0 +1,180 @@
+package fulltext
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxTextSize limita el texto extraído por archivo (los documentos enormes se truncan)
+const maxTextSize = 2 * 1024 * 1024
+
+// plainTextExtensions son los formatos que se indexan tal cual
+var plainTextExtensions = map[string]bool{
+	".txt": true, ".csv": true, ".tsv": true, ".md": true, ".json": true,
+	".xml": true, ".html": true, ".htm": true, ".log": true,
+}
+
+// Extractable indica si hay un extractor de texto para la extensión
+func Extractable(ext string) bool {
+	ext = strings.ToLower(ext)
+	if plainTextExtensions[ext] {
+		return true
+	}
+	switch ext {
+	case ".pdf", ".docx", ".xlsx", ".odt", ".ods", ".odp":
+		return true
+	}
+	return false
+}
+
+// ExtractText extrae el texto plano de un archivo según su extensión
+func ExtractText(path, ext string) (string, error) {
+	ext = strings.ToLower(ext)
+
+	switch {
+	case plainTextExtensions[ext]:
+		return extractPlain(path)
+	case ext == ".pdf":
+		return extractPDF(path)
+	case ext == ".docx":
+		return extractZipXML(path, []string{"word/document.xml"}, "t", "p")
+	case ext == ".xlsx":
+		return extractXLSX(path)
+	case ext == ".odt" || ext == ".ods" || ext == ".odp":
+		return extractZipXML(path, []string{"content.xml"}, "", "p")
+	default:
+		return "", fmt.Errorf("formato no soportado: %s", ext)
+	}
+}
+
+// extractPlain lee archivos de texto descartando contenido que no sea UTF-8 válido
+func extractPlain(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxTextSize))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(data), " "), nil
+}
+
+// extractPDF extrae el texto de todas las páginas de un PDF
+func extractPDF(path string) (text string, err error) {
+	// La librería entra en pánico con PDFs malformados
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("PDF no legible: %v", r)
+		}
+	}()
+
+	file, reader, err := pdf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(plain, maxTextSize))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(data), " "), nil
+}
+
+// extractXLSX extrae las cadenas compartidas y las celdas con texto inline
+func extractXLSX(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	names := []string{"xl/sharedStrings.xml"}
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, "xl/worksheets/") && strings.HasSuffix(f.Name, ".xml") {
+			names = append(names, f.Name)
+		}
+	}
+	return zipXMLText(&zr.Reader, names, "t", "si")
+}
+
+// extractZipXML extrae el texto de documentos Office/OpenDocument (ZIP con XML)
+func extractZipXML(path string, names []string, textElem, paraElem string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	return zipXMLText(&zr.Reader, names, textElem, paraElem)
+}
+
+// zipXMLText recorre los XML indicados dentro de un ZIP acumulando el texto.
+// Si textElem está vacío se toma todo el texto; paraElem agrega un salto de línea al cerrar.
+func zipXMLText(zr *zip.Reader, names []string, textElem, paraElem string) (string, error) {
+	var out strings.Builder
+
+	for _, name := range names {
+		for _, f := range zr.File {
+			if f.Name != name {
+				continue
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				return "", err
+			}
+
+			decoder := xml.NewDecoder(io.LimitReader(rc, 8*maxTextSize))
+			depth := 0 // profundidad dentro de elementos de texto
+			for out.Len() < maxTextSize {
+				tok, err := decoder.Token()
+				if err != nil {
+					break
+				}
+				switch t := tok.(type) {
+				case xml.StartElement:
+					if t.Name.Local == textElem {
+						depth++
+					}
+				case xml.EndElement:
+					if t.Name.Local == textElem && depth > 0 {
+						depth--
+					}
+					if t.Name.Local == paraElem {
+						out.WriteByte('\n')
+					}
+				case xml.CharData:
+					if textElem == "" || depth > 0 {
+						out.Write(t)
+					}
+				}
+			}
+			rc.Close()
+		}
+	}
+
+	text := out.String()
+	if len(text) > maxTextSize {
+		text = text[:maxTextSize]
+		for !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	return text, nil
+}
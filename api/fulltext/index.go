@@ -0,0 +1,373 @@
+package fulltext
+
+import (
+	"html"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+)
+
+// TextDir es el directorio oculto donde se guarda el texto extraído de cada archivo
+const TextDir = ".text"
+
+// Parámetros de ranking BM25
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// clientIndex es el índice invertido de contenido de un cliente
+type clientIndex struct {
+	postings    map[string]map[string]int // término -> fileID -> frecuencia
+	lengths     map[string]int            // fileID -> cantidad de términos
+	totalLength int
+}
+
+var (
+	mu      sync.RWMutex
+	clients = make(map[string]*clientIndex)
+)
+
+// getClient retorna (creando si hace falta) el índice de un cliente. Requiere mu tomado.
+func getClient(clientID string) *clientIndex {
+	ci, ok := clients[clientID]
+	if !ok {
+		ci = &clientIndex{
+			postings: make(map[string]map[string]int),
+			lengths:  make(map[string]int),
+		}
+		clients[clientID] = ci
+	}
+	return ci
+}
+
+// textPath retorna la ruta del texto extraído de un archivo
+func textPath(storagePath, fileID string) string {
+	return filepath.Join(storage.ClientRoot(storagePath), TextDir, fileID+".txt")
+}
+
+// IndexFile extrae el texto de un archivo, lo persiste y lo agrega al índice.
+// Los formatos sin extractor se ignoran sin error.
+func IndexFile(storagePath string, metadata models.FileMetadata) error {
+	if !Extractable(metadata.Extension) {
+		return nil
+	}
+
+	text, err := ExtractText(metadata.Path, metadata.Extension)
+	if err != nil {
+		return err
+	}
+
+	path := textPath(storagePath, metadata.FileID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return err
+	}
+
+	addDocument(metadata.Client, metadata.FileID, text)
+	return nil
+}
+
+// RemoveFile quita un archivo del índice y borra su texto extraído
+func RemoveFile(storagePath, clientID, fileID string) {
+	removeDocument(clientID, fileID)
+	os.Remove(textPath(storagePath, fileID))
+}
+
+// LoadClient carga el índice de un cliente desde los textos ya extraídos y
+// extrae los archivos que todavía no tienen texto (archivos legacy)
+func LoadClient(clientID, storagePath string, files []models.FileMetadata) {
+	for _, file := range files {
+		if !Extractable(file.Extension) {
+			continue
+		}
+
+		if data, err := os.ReadFile(textPath(storagePath, file.FileID)); err == nil {
+			addDocument(clientID, file.FileID, string(data))
+			continue
+		}
+
+		if err := IndexFile(storagePath, file); err != nil {
+			slog.Warn("no se pudo extraer texto", "client", clientID, "file_id", file.FileID, "error", err)
+		}
+	}
+}
+
+// addDocument agrega (o reemplaza) un documento en el índice
+func addDocument(clientID, fileID, text string) {
+	freqs := make(map[string]int)
+	count := 0
+	for _, term := range tokenize(text) {
+		freqs[term.value]++
+		count++
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	ci := getClient(clientID)
+	ci.remove(fileID)
+
+	for term, freq := range freqs {
+		if ci.postings[term] == nil {
+			ci.postings[term] = make(map[string]int)
+		}
+		ci.postings[term][fileID] = freq
+	}
+	ci.lengths[fileID] = count
+	ci.totalLength += count
+}
+
+// removeDocument quita un documento del índice
+func removeDocument(clientID, fileID string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if ci, ok := clients[clientID]; ok {
+		ci.remove(fileID)
+	}
+}
+
+// remove quita un documento de un índice de cliente. Requiere mu tomado.
+func (ci *clientIndex) remove(fileID string) {
+	length, ok := ci.lengths[fileID]
+	if !ok {
+		return
+	}
+	for term, docs := range ci.postings {
+		if _, ok := docs[fileID]; ok {
+			delete(docs, fileID)
+			if len(docs) == 0 {
+				delete(ci.postings, term)
+			}
+		}
+	}
+	delete(ci.lengths, fileID)
+	ci.totalLength -= length
+}
+
+// Search busca archivos cuyo contenido contenga todos los términos de la consulta
+// y retorna su puntaje BM25. Un término terminado en * busca por prefijo.
+func Search(clientID, query string) map[string]float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	results := make(map[string]float64)
+	ci, ok := clients[clientID]
+	if !ok || len(ci.lengths) == 0 {
+		return results
+	}
+
+	queryTerms := parseQueryTerms(query)
+	if len(queryTerms) == 0 {
+		return results
+	}
+
+	docCount := float64(len(ci.lengths))
+	avgLength := float64(ci.totalLength) / docCount
+
+	for i, qt := range queryTerms {
+		// Puntaje de este término de la consulta por documento (sumando expansiones de prefijo)
+		termScores := make(map[string]float64)
+		for _, term := range ci.expand(qt) {
+			docs := ci.postings[term]
+			df := float64(len(docs))
+			idf := math.Log(1 + (docCount-df+0.5)/(df+0.5))
+			for fileID, freq := range docs {
+				tf := float64(freq)
+				norm := 1 - bm25B + bm25B*float64(ci.lengths[fileID])/avgLength
+				termScores[fileID] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+			}
+		}
+
+		// Todos los términos son obligatorios
+		if i == 0 {
+			results = termScores
+			continue
+		}
+		for fileID, score := range results {
+			if extra, ok := termScores[fileID]; ok {
+				results[fileID] = score + extra
+			} else {
+				delete(results, fileID)
+			}
+		}
+	}
+
+	return results
+}
+
+// queryTerm es un término de búsqueda, opcionalmente por prefijo
+type queryTerm struct {
+	value  string
+	prefix bool
+}
+
+// parseQueryTerms normaliza los términos de una consulta de contenido
+func parseQueryTerms(query string) []queryTerm {
+	var terms []queryTerm
+	seen := make(map[string]bool)
+
+	for _, word := range strings.Fields(query) {
+		prefix := strings.HasSuffix(word, "*")
+		for _, tok := range tokenize(strings.TrimSuffix(word, "*")) {
+			key := tok.value
+			if prefix {
+				key += "*"
+			}
+			if !seen[key] {
+				seen[key] = true
+				terms = append(terms, queryTerm{value: tok.value, prefix: prefix})
+			}
+		}
+	}
+	return terms
+}
+
+// expand retorna los términos del índice que corresponden a un término de la consulta
+func (ci *clientIndex) expand(qt queryTerm) []string {
+	if !qt.prefix {
+		return []string{qt.value}
+	}
+	var terms []string
+	for term := range ci.postings {
+		if strings.HasPrefix(term, qt.value) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Snippets retorna hasta max fragmentos del texto de un archivo con los términos
+// de la consulta resaltados con <mark>. El texto se escapa como HTML.
+func Snippets(storagePath, fileID, query string, max int) []string {
+	data, err := os.ReadFile(textPath(storagePath, fileID))
+	if err != nil {
+		return nil
+	}
+	text := string(data)
+	queryTerms := parseQueryTerms(query)
+
+	matchesTerm := func(value string) bool {
+		for _, qt := range queryTerms {
+			if value == qt.value || (qt.prefix && strings.HasPrefix(value, qt.value)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	const context = 60
+	var snippets []string
+	lastEnd := -1
+
+	for _, tok := range tokenize(text) {
+		if len(snippets) >= max {
+			break
+		}
+		if tok.start < lastEnd || !matchesTerm(tok.value) {
+			continue
+		}
+
+		start := runeBoundary(text, tok.start-context)
+		end := runeBoundary(text, tok.end+context)
+
+		var b strings.Builder
+		if start > 0 {
+			b.WriteString("…")
+		}
+		b.WriteString(highlight(text[start:end], matchesTerm))
+		if end < len(text) {
+			b.WriteString("…")
+		}
+
+		snippets = append(snippets, strings.Join(strings.Fields(b.String()), " "))
+		lastEnd = end
+	}
+
+	return snippets
+}
+
+// highlight escapa un fragmento y marca los términos que coinciden
+func highlight(fragment string, matches func(string) bool) string {
+	var b strings.Builder
+	pos := 0
+	for _, tok := range tokenize(fragment) {
+		if !matches(tok.value) {
+			continue
+		}
+		b.WriteString(html.EscapeString(fragment[pos:tok.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(fragment[tok.start:tok.end]))
+		b.WriteString("</mark>")
+		pos = tok.end
+	}
+	b.WriteString(html.EscapeString(fragment[pos:]))
+	return b.String()
+}
+
+// runeBoundary ajusta una posición de byte al inicio de una runa válida dentro del texto
+func runeBoundary(text string, pos int) int {
+	if pos <= 0 {
+		return 0
+	}
+	if pos >= len(text) {
+		return len(text)
+	}
+	for pos > 0 && !isRuneStart(text[pos]) {
+		pos--
+	}
+	return pos
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// token es un término normalizado con su posición en el texto original
+type token struct {
+	value      string
+	start, end int
+}
+
+// accentFolder quita acentos para que "catálogo" y "catalogo" coincidan
+var accentFolder = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u",
+	"â", "a", "ê", "e", "î", "i", "ô", "o", "û", "u",
+	"ä", "a", "ë", "e", "ï", "i", "ö", "o", "ç", "c", "ñ", "n",
+)
+
+// tokenize separa un texto en términos normalizados (minúsculas, sin acentos)
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 && end-start >= 2 {
+			value := accentFolder.Replace(strings.ToLower(text[start:end]))
+			tokens = append(tokens, token{value: value, start: start, end: end})
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			flush(i)
+		}
+	}
+	flush(len(text))
+
+	return tokens
+}
@@ -0,0 +1,128 @@
+package fulltext
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// loadDocs arma un índice de prueba para un cliente propio del test
+func loadDocs(t *testing.T, docs map[string]string) string {
+	t.Helper()
+	clientID := "test-" + t.Name()
+	for fileID, text := range docs {
+		addDocument(clientID, fileID, text)
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(clients, clientID)
+		mu.Unlock()
+	})
+	return clientID
+}
+
+// ranked retorna los fileIds ordenados por puntaje descendente
+func ranked(scores map[string]float64) string {
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return strings.Join(ids, ",")
+}
+
+func TestSearchRanking(t *testing.T) {
+	docs := map[string]string{
+		"manual":   "Manual de la bomba hidráulica. La bomba se instala con la válvula. Bomba de repuesto.",
+		"catalogo": "Catálogo de productos: bomba, válvula, manguera, filtro, tanque, motor, correa y accesorios varios.",
+		"factura":  "Factura por una bomba sumergible.",
+		"nota":     "Nota interna sobre el motor del tanque.",
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"más frecuencia primero", "bomba", "manual,factura,catalogo"},
+		{"todos los términos son obligatorios", "bomba valvula", "manual,catalogo"},
+		{"sin acentos ni mayúsculas", "HIDRAULICA", "manual"},
+		{"prefijo", "hidraul*", "manual"},
+		{"prefijo de varios términos", "mot*", "nota,catalogo"},
+		{"término raro pesa más", "sumergible bomba", "factura"},
+		{"sin resultados", "compresor", ""},
+		{"término faltante anula el resto", "bomba compresor", ""},
+		{"consulta vacía", "  ", ""},
+		{"términos de una letra se ignoran", "a", ""},
+	}
+
+	clientID := loadDocs(t, docs)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ranked(Search(clientID, tt.query)); got != tt.want {
+				t.Errorf("Search(%q) = %s, esperado %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchBM25Score(t *testing.T) {
+	// Dos documentos: "bomba bomba" (largo 2) y "motor tanque correa filtro" (largo 4)
+	clientID := loadDocs(t, map[string]string{
+		"a": "bomba bomba",
+		"b": "motor tanque correa filtro",
+	})
+
+	docCount, df, tf, length, avg := 2.0, 1.0, 2.0, 2.0, 3.0
+	idf := math.Log(1 + (docCount-df+0.5)/(df+0.5))
+	norm := 1 - bm25B + bm25B*length/avg
+	want := idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+
+	got := Search(clientID, "bomba")
+	if len(got) != 1 || math.Abs(got["a"]-want) > 1e-9 {
+		t.Errorf("Search(bomba) = %v, esperado a=%v", got, want)
+	}
+}
+
+func TestSearchShorterDocumentWins(t *testing.T) {
+	// Misma frecuencia: la normalización por largo favorece al documento corto
+	clientID := loadDocs(t, map[string]string{
+		"corto": "bomba nueva",
+		"largo": "bomba usada con motor tanque correa filtro manguera válvula",
+	})
+	if got := ranked(Search(clientID, "bomba")); got != "corto,largo" {
+		t.Errorf("Search(bomba) = %s, esperado corto,largo", got)
+	}
+}
+
+func TestRemoveDocumentUpdatesStats(t *testing.T) {
+	clientID := loadDocs(t, map[string]string{
+		"a": "bomba hidráulica",
+		"b": "bomba sumergible de repuesto",
+	})
+
+	removeDocument(clientID, "b")
+	if got := ranked(Search(clientID, "bomba")); got != "a" {
+		t.Errorf("Search(bomba) tras borrar b = %s, esperado a", got)
+	}
+
+	mu.RLock()
+	ci := clients[clientID]
+	total, postings := ci.totalLength, len(ci.postings)
+	mu.RUnlock()
+	if total != 2 || postings != 2 {
+		t.Errorf("tras borrar b: totalLength = %d, términos = %d; esperado 2 y 2", total, postings)
+	}
+
+	// Reindexar reemplaza el documento en lugar de sumar términos
+	addDocument(clientID, "a", "motor")
+	if got := ranked(Search(clientID, "bomba")); got != "" {
+		t.Errorf("Search(bomba) tras reindexar a = %s, esperado sin resultados", got)
+	}
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// manifestName es el archivo con los SHA-256 de cada entrada (formato sha256sum)
+const manifestName = "MANIFEST.sha256"
+
+// archiveEntry es un archivo del cliente y su ruta dentro del ZIP/tar.gz
+type archiveEntry struct {
+	name string
+	file models.FileMetadata
+}
+
+// ArchiveFiles descarga varios archivos como un ZIP o tar.gz generado al vuelo.
+// El archivo se escribe directo en la respuesta, sin copias temporales en disco.
+func ArchiveFiles(w http.ResponseWriter, r *http.Request) {
+	// Obtener client ID del contexto
+	clientID := middleware.GetClientFromContext(r.Context())
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
+		return
+	}
+
+	var archiveReq models.ArchiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&archiveReq); err != nil {
+		sendErrorResponse(w, "JSON inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var contentType, extension string
+	switch archiveReq.Format {
+	case "", "zip":
+		contentType, extension = "application/zip", ".zip"
+	case "tar.gz", "tgz":
+		contentType, extension = "application/gzip", ".tar.gz"
+	default:
+		sendErrorResponse(w, "format debe ser zip o tar.gz", http.StatusBadRequest)
+		return
+	}
+
+	files, status, err := archiveSelection(r.Context(), clientID, archiveReq)
+	if err != nil {
+		sendErrorResponse(w, err.Error(), status)
+		return
+	}
+	if len(files) == 0 {
+		sendErrorResponse(w, "No hay archivos para descargar", http.StatusNotFound)
+		return
+	}
+
+	// Validar el tamaño total antes de empezar a enviar
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.Size
+	}
+	if maxSize := clientConfig.GetMaxArchiveSize(); totalSize > maxSize {
+		sendErrorResponse(w, fmt.Sprintf("La descarga suma %d bytes y supera el máximo de %d bytes", totalSize, maxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	name := archiveReq.Name
+	if name == "" {
+		name = clientID + "-" + time.Now().Format("20060102-150405")
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", name+extension, contentType))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	var archive archiveWriter
+	if extension == ".zip" {
+		archive = &zipArchive{Writer: zip.NewWriter(w)}
+	} else {
+		gz := gzip.NewWriter(w)
+		archive = &tarArchive{Writer: tar.NewWriter(gz), gz: gz}
+	}
+
+	_, span := tracing.Start(r.Context(), "archive.Write", tracing.Client(clientID), tracing.Size(totalSize),
+		attribute.String("archive.format", archiveReq.Format), attribute.Int("archive.files", len(files)))
+	err = writeArchive(archive, archiveEntries(files))
+	tracing.End(span, err)
+	if err != nil {
+		// Los headers ya se enviaron: el archivo queda sin cerrar (ZIP sin directorio
+		// central, gzip truncado) para que el cliente detecte la descarga incompleta
+		middleware.Logger(r.Context()).Warn("descarga masiva interrumpida", "files", len(files), "error", err)
+	}
+}
+
+// archiveSelection resuelve los archivos pedidos por fileIds, carpeta o búsqueda.
+// Retorna el status HTTP a usar si hay error.
+func archiveSelection(ctx context.Context, clientID string, archiveReq models.ArchiveRequest) ([]models.FileMetadata, int, error) {
+	selectors := 0
+	if len(archiveReq.FileIDs) > 0 {
+		selectors++
+	}
+	if archiveReq.Folder != nil {
+		selectors++
+	}
+	if archiveReq.Search != nil {
+		selectors++
+	}
+	if selectors != 1 {
+		return nil, http.StatusBadRequest, fmt.Errorf("Indicar solo uno de fileIds, folder o search")
+	}
+
+	switch {
+	case len(archiveReq.FileIDs) > 0:
+		var files []models.FileMetadata
+		seen := make(map[string]bool)
+		var missing []string
+		for _, fileID := range archiveReq.FileIDs {
+			if seen[fileID] {
+				continue
+			}
+			seen[fileID] = true
+			metadata, ok := storage.LookupFile(ctx, clientID, fileID)
+			if !ok {
+				missing = append(missing, fileID)
+				continue
+			}
+			if status, msg := scanBlock(&metadata); status != 0 {
+				return nil, status, fmt.Errorf("%s: %s", msg, fileID)
+			}
+			files = append(files, metadata)
+		}
+		if len(missing) > 0 {
+			return nil, http.StatusNotFound, fmt.Errorf("Archivos no encontrados: %s", strings.Join(missing, ", "))
+		}
+		return files, http.StatusOK, nil
+
+	case archiveReq.Folder != nil:
+		folder := strings.Trim(*archiveReq.Folder, "/")
+		files, err := scanClientFiles(ctx, clientID)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Error al listar archivos: %v", err)
+		}
+		var filtered []models.FileMetadata
+		for _, file := range files {
+			if folder == "" || file.Folder == folder || strings.HasPrefix(file.Folder, folder+"/") {
+				filtered = append(filtered, file)
+			}
+		}
+		return downloadable(filtered), http.StatusOK, nil
+
+	default:
+		parsed, err := parseQuery(archiveReq.Search.Query)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Query inválida: %v", err)
+		}
+		files, _, err := searchMatches(ctx, clientID, parsed, *archiveReq.Search)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("Error al buscar archivos: %v", err)
+		}
+		// searchMatches ya descarta los archivos bloqueados por el antivirus
+		return files, http.StatusOK, nil
+	}
+}
+
+// downloadable descarta de una carpeta o búsqueda los archivos que el antivirus
+// todavía no analizó o marcó como malware
+func downloadable(files []models.FileMetadata) []models.FileMetadata {
+	result := files[:0]
+	for i := range files {
+		if status, _ := scanBlock(&files[i]); status == 0 {
+			result = append(result, files[i])
+		}
+	}
+	return result
+}
+
+// archiveEntries arma las rutas carpeta/nombre original de cada archivo,
+// numerando los nombres repetidos ("informe (2).pdf")
+func archiveEntries(files []models.FileMetadata) []archiveEntry {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Folder != files[j].Folder {
+			return files[i].Folder < files[j].Folder
+		}
+		return files[i].OriginalName < files[j].OriginalName
+	})
+
+	used := map[string]bool{manifestName: true}
+	entries := make([]archiveEntry, 0, len(files))
+	for _, file := range files {
+		// Evitar rutas absolutas o con ".." dentro del archivo (zip slip)
+		base := strings.NewReplacer("/", "_", "\\", "_").Replace(file.OriginalName)
+		if base == "" || base == "." || base == ".." {
+			base = file.FileName
+		}
+		var folderParts []string
+		for _, part := range strings.Split(file.Folder, "/") {
+			if part != "" && part != "." && part != ".." {
+				folderParts = append(folderParts, part)
+			}
+		}
+		name := path.Join(append(folderParts, base)...)
+
+		ext := path.Ext(name)
+		stem := strings.TrimSuffix(name, ext)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+		}
+		used[name] = true
+		entries = append(entries, archiveEntry{name: name, file: file})
+	}
+	return entries
+}
+
+// archiveWriter abstrae el formato de la descarga masiva
+type archiveWriter interface {
+	create(name string, size int64, modTime time.Time, mimeType string) (io.Writer, error)
+	Close() error
+}
+
+// writeArchive copia cada archivo calculando su SHA-256 y agrega el manifiesto al final
+func writeArchive(archive archiveWriter, entries []archiveEntry) error {
+	var manifest strings.Builder
+	for _, entry := range entries {
+		hash, err := writeArchiveEntry(archive, entry)
+		if err != nil {
+			return fmt.Errorf("%s: %v", entry.name, err)
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", hash, entry.name)
+	}
+
+	out, err := archive.create(manifestName, int64(manifest.Len()), time.Now(), "text/plain")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(out, manifest.String()); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writeArchiveEntry agrega un archivo y retorna el hash de lo que se escribió
+func writeArchiveEntry(archive archiveWriter, entry archiveEntry) (string, error) {
+	file, err := os.Open(entry.file.Path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := archive.create(entry.name, stat.Size(), entry.file.UploadedAt, entry.file.MimeType)
+	if err != nil {
+		return "", err
+	}
+	hasher := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(out, hasher), file, stat.Size()); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// zipArchive escribe un ZIP; solo comprime los tipos que se benefician (texto)
+type zipArchive struct {
+	*zip.Writer
+}
+
+func (a *zipArchive) create(name string, size int64, modTime time.Time, mimeType string) (io.Writer, error) {
+	method := zip.Store
+	if compressibleType(mimeType) {
+		method = zip.Deflate
+	}
+	return a.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modTime})
+}
+
+// tarArchive escribe un tar comprimido con gzip
+type tarArchive struct {
+	*tar.Writer
+	gz *gzip.Writer
+}
+
+func (a *tarArchive) create(name string, size int64, modTime time.Time, mimeType string) (io.Writer, error) {
+	err := a.WriteHeader(&tar.Header{
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+		Format:   tar.FormatPAX, // nombres UTF-8 largos
+	})
+	return a.Writer, err
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.Writer.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// compressibleType indica si vale la pena comprimir el tipo MIME (imágenes,
+// PDFs y formatos de Office ya vienen comprimidos)
+func compressibleType(mimeType string) bool {
+	base := mimeBase(mimeType)
+	return strings.HasPrefix(base, "text/") || strings.HasSuffix(base, "json") || strings.HasSuffix(base, "xml")
+}
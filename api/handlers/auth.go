@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"file-server-sofmar/config"
+	"file-server-sofmar/metrics"
+	"file-server-sofmar/middleware"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -18,10 +23,11 @@ type LoginRequest struct {
 
 // LoginResponse representa la respuesta del login
 type LoginResponse struct {
-	Success bool   `json:"success"`
-	Token   string `json:"token,omitempty"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success   bool   `json:"success"`
+	Token     string `json:"token,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Error     string `json:"error,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
 }
 
 // Login maneja la autenticación básica
@@ -30,27 +36,57 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"success":false,"error":"Datos inválidos"}`, http.StatusBadRequest)
+		sendErrorResponse(w, "Datos inválidos", http.StatusBadRequest)
 		return
 	}
 
-	// Verificar credenciales
-	if req.Username != cfg.AdminUser || req.Password != cfg.AdminPassword {
+	// Rechazar sin verificar credenciales si el usuario o la IP están bloqueados
+	ip := middleware.ClientIP(r)
+	logger := middleware.Logger(r.Context())
+	if wait := guard.blocked(req.Username, ip); wait > 0 {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		metrics.AuthFailures.WithLabelValues("login_locked").Inc()
+		logger.Warn("intento de login bloqueado", "event", "login_blocked", "username", req.Username, "retry_after", retryAfter)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(LoginResponse{
+			Success:   false,
+			Error:     "Demasiados intentos fallidos, intente nuevamente en " + strconv.Itoa(retryAfter) + " segundos",
+			RequestID: middleware.GetRequestIDFromContext(r.Context()),
+		})
+		return
+	}
+
+	// Verificar credenciales (comparación en tiempo constante)
+	validUser := subtle.ConstantTimeCompare([]byte(req.Username), []byte(cfg.AdminUser)) == 1
+	validPassword := subtle.ConstantTimeCompare([]byte(req.Password), []byte(cfg.AdminPassword)) == 1
+	if !validUser || !validPassword {
+		metrics.AuthFailures.WithLabelValues("login_failed").Inc()
+		logger.Warn("login fallido", "event", "login_failed", "username", req.Username)
+		if lockout := guard.fail(req.Username, ip); lockout > 0 {
+			logger.Warn("login bloqueado", "event", "login_locked", "username", req.Username, "lockout", lockout.String())
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(LoginResponse{
-			Success: false,
-			Error:   "Usuario o contraseña incorrectos",
+			Success:   false,
+			Error:     "Usuario o contraseña incorrectos",
+			RequestID: middleware.GetRequestIDFromContext(r.Context()),
 		})
 		return
 	}
 
+	guard.succeed(req.Username, ip)
+	logger.Info("login exitoso", "event", "login_succeeded", "username", req.Username)
+
 	// Generar JWT token
+	expiresAt := time.Now().Add(time.Hour * 24) // 24 horas
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user":     req.Username,
-		"client":   "shared",
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // 24 horas
-		"iat":      time.Now().Unix(),
+		"user":   req.Username,
+		"client": "shared",
+		"exp":    expiresAt.Unix(),
+		"iat":    time.Now().Unix(),
 	})
 
 	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
@@ -58,12 +94,24 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(LoginResponse{
-			Success: false,
-			Error:   "Error generando token",
+			Success:   false,
+			Error:     "Error generando token",
+			RequestID: middleware.GetRequestIDFromContext(r.Context()),
 		})
 		return
 	}
 
+	// Cookie para las URLs /static (solo lectura, no se envía a /api)
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.AuthCookieName,
+		Value:    tokenString,
+		Path:     "/static/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
 	// Respuesta exitosa
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(LoginResponse{
@@ -71,4 +119,4 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		Token:   tokenString,
 		Message: "Login exitoso",
 	})
-}
\ No newline at end of file
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/tracing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CopyFile copia un archivo a otra carpeta o a otro cliente sin pasar
+// el contenido por quien hace la request
+func CopyFile(w http.ResponseWriter, r *http.Request) {
+	// Obtener fileId de la URL
+	vars := mux.Vars(r)
+	fileID := vars["fileId"]
+	if fileID == "" {
+		sendErrorResponse(w, "ID de archivo requerido", http.StatusBadRequest)
+		return
+	}
+
+	// Cliente de origen (del contexto)
+	clientID := middleware.GetClientFromContext(r.Context())
+	_, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
+		return
+	}
+
+	var copyReq models.CopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&copyReq); err != nil {
+		sendErrorResponse(w, "JSON inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Cliente destino (por defecto el mismo cliente)
+	targetClient := copyReq.TargetClient
+	if targetClient == "" {
+		targetClient = clientID
+	}
+	targetConfig, exists := config.GetClientConfig(targetClient)
+	if !exists {
+		sendErrorResponse(w, "Cliente destino no configurado: "+targetClient, http.StatusBadRequest)
+		return
+	}
+
+	// Copiar a otro cliente requiere permisos también sobre el destino
+	if targetClient != clientID {
+		if err := middleware.AuthorizeClient(r, targetClient); err != nil {
+			sendErrorResponse(w, "Sin permisos sobre el cliente destino: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	// Buscar archivo de origen
+	source, err := findFileByID(r.Context(), fileID, clientID)
+	if err != nil {
+		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if status, msg := scanBlock(source); status != 0 {
+		sendErrorResponse(w, msg, status)
+		return
+	}
+
+	// Revalidar contra los límites del cliente destino
+	if source.Size > targetConfig.MaxFileSize {
+		sendErrorResponse(w, fmt.Sprintf("Archivo demasiado grande para %s. Máximo: %d bytes", targetClient, targetConfig.MaxFileSize), http.StatusBadRequest)
+		return
+	}
+	if !isAllowedFileType(source.OriginalName, targetConfig.AllowedTypes) {
+		sendErrorResponse(w, "Tipo de archivo no permitido en "+targetClient, http.StatusBadRequest)
+		return
+	}
+
+	if err := validateCustomFields(targetConfig.MetadataSchema, source.Custom); err != nil {
+		sendErrorResponse(w, "Metadata no válida para "+targetClient+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	folder := sanitizeFolder(copyReq.TargetFolder)
+
+	// Nuevo ID para la copia, conservando la extensión
+	newID := uuid.New().String()
+	fileName := newID + source.Extension
+	filePath := filepath.Join(storage.ClientRoot(targetConfig.StoragePath), folder, fileName)
+
+	// Como en las subidas, la copia se escribe en staging hasta que el
+	// antivirus del cliente destino la aprueba
+	stagedPath, err := storage.StagingPath(targetConfig.StoragePath, fileName)
+	if err != nil {
+		sendErrorResponse(w, "Error al crear directorio: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	release := storage.TrackPartial(stagedPath)
+	defer release()
+	_, span := tracing.Start(r.Context(), "storage.CopyFile", tracing.Client(targetClient), tracing.File(newID), tracing.Size(source.Size))
+	fileHash, size, err := copyFileContents(source.Path, stagedPath, source.Hash)
+	tracing.End(span, err)
+	if err != nil {
+		os.Remove(stagedPath)
+		sendErrorResponse(w, "Error al copiar archivo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// El cliente destino puede exigir análisis antivirus; un análisis limpio del
+	// mismo contenido en el origen se reutiliza
+	scan := source.Scan
+	if targetConfig.MalwareScan != nil && (scan == nil || scan.Status != models.ScanClean || fileHash != source.Hash) {
+		scan, err = scanFile(r.Context(), targetClient, targetConfig, newID, stagedPath)
+		if err != nil {
+			os.Remove(stagedPath)
+			sendErrorResponse(w, "No se pudo analizar el archivo con el antivirus: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if scan.Status == models.ScanInfected {
+			rejectInfected(w, r, targetConfig.StoragePath, models.FileMetadata{
+				FileID:       newID,
+				OriginalName: source.OriginalName,
+				FileName:     fileName,
+				Client:       targetClient,
+				Folder:       folder,
+				Size:         size,
+				Extension:    source.Extension,
+				UploadedAt:   time.Now(),
+				Path:         stagedPath,
+				Hash:         fileHash,
+				Scan:         scan,
+			})
+			return
+		}
+	}
+
+	// El cliente destino puede exigir que las fotos no conserven la ubicación
+	extended := source.Extended
+	if targetConfig.StripGPS && extended != nil && extended.GPS != nil {
+		fileHash, err = stripGPS(r.Context(), stagedPath, source.Extension, fileHash)
+		if err != nil {
+			os.Remove(stagedPath)
+			sendErrorResponse(w, "Error al quitar la ubicación de la imagen: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		withoutGPS := *extended
+		withoutGPS.GPS = nil
+		extended = &withoutGPS
+	}
+
+	metadata := models.FileMetadata{
+		FileID:       newID,
+		OriginalName: source.OriginalName,
+		FileName:     fileName,
+		Client:       targetClient,
+		Folder:       folder,
+		Size:         size,
+		MimeType:     source.MimeType,
+		Extension:    source.Extension,
+		UploadedAt:   time.Now(),
+		URL:          storage.FileURL(targetClient, folder, fileName),
+		Path:         filePath,
+		Hash:         fileHash,
+		Thumbnails:   storage.ThumbnailURLs(newID, source.Extension),
+		Tags:         source.Tags,
+		Custom:       source.Custom,
+		Extended:     extended,
+		Scan:         scan,
+	}
+
+	// Publicar en la carpeta final (ver UploadFile)
+	releasePublished := storage.TrackPartial(filePath)
+	defer releasePublished()
+	if err := storage.Publish(stagedPath, filePath); err != nil {
+		os.Remove(stagedPath)
+		sendErrorResponse(w, "Error al copiar archivo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	release()
+
+	if err := storage.SaveMetadata(r.Context(), targetConfig.StoragePath, metadata); err != nil {
+		os.Remove(filePath)
+		sendErrorResponse(w, "Error al guardar metadata: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	storage.IndexFile(metadata)
+	releasePublished()
+	processContent(middleware.Logger(r.Context()), targetConfig.StoragePath, metadata)
+
+	response := models.UploadResponse{
+		Success: true,
+		Data:    metadata,
+		Message: "Archivo copiado exitosamente",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// copyFileContents copia un archivo en disco. Si no se conoce el hash de origen
+// (archivos legacy) lo calcula durante la copia.
+func copyFileContents(srcPath, dstPath, knownHash string) (string, int64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", 0, err
+	}
+	defer dst.Close()
+
+	if knownHash != "" {
+		// Copia directa entre archivos (el kernel puede evitar pasar por user space)
+		size, err := io.Copy(dst, src)
+		return knownHash, size, err
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hasher), src)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
@@ -6,8 +6,13 @@ import (
 	"os"
 
 	"file-server-sofmar/config"
+	"file-server-sofmar/fulltext"
+	"file-server-sofmar/imaging"
 	"file-server-sofmar/middleware"
 	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/thumbnail"
+	"file-server-sofmar/tracing"
 
 	"github.com/gorilla/mux"
 )
@@ -30,8 +35,12 @@ func DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Bloquear la metadata para que un PATCH concurrente no la vuelva a crear
+	unlock := storage.LockFile(clientID, fileID)
+	defer unlock()
+
 	// Buscar archivo por ID
-	fileInfo, err := findFileByID(fileID, clientID, clientConfig.StoragePath)
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
 	if err != nil {
 		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
 		return
@@ -51,14 +60,23 @@ func DeleteFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Eliminar archivo del filesystem
+	_, span := tracing.Start(r.Context(), "storage.RemoveFile", tracing.Client(clientID), tracing.File(fileID))
 	err = os.Remove(fileInfo.Path)
+	tracing.End(span, err)
 	if err != nil {
 		sendErrorResponse(w, "Error al eliminar archivo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// TODO: Si usamos base de datos, eliminar metadata también
-	// db.DeleteFileMetadata(fileID)
+	storage.UnindexFile(clientID, fileID)
+	fulltext.RemoveFile(clientConfig.StoragePath, clientID, fileID)
+	thumbnail.Remove(clientConfig.StoragePath, fileID)
+	imaging.RemoveCached(clientConfig.StoragePath, fileID)
+
+	// Eliminar metadata persistida
+	if err := storage.DeleteMetadata(r.Context(), clientConfig.StoragePath, fileID); err != nil {
+		middleware.Logger(r.Context()).Error("error eliminando metadata", "file_id", fileID, "error", err)
+	}
 
 	// Respuesta exitosa
 	response := map[string]interface{}{
@@ -120,7 +138,7 @@ func BulkDelete(w http.ResponseWriter, r *http.Request) {
 
 	// Procesar cada archivo
 	for _, fileID := range request.FileIDs {
-		fileInfo, err := findFileByID(fileID, clientID, clientConfig.StoragePath)
+		fileInfo, err := findFileByID(r.Context(), fileID, clientID)
 		if err != nil {
 			errorFiles = append(errorFiles, map[string]string{
 				"fileId": fileID,
@@ -140,14 +158,22 @@ func BulkDelete(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Eliminar archivo
+		unlock := storage.LockFile(clientID, fileID)
 		err = os.Remove(fileInfo.Path)
 		if err != nil {
+			unlock()
 			errorFiles = append(errorFiles, map[string]string{
 				"fileId": fileID,
 				"error":  "Error al eliminar: " + err.Error(),
 			})
 			continue
 		}
+		storage.UnindexFile(clientID, fileID)
+		fulltext.RemoveFile(clientConfig.StoragePath, clientID, fileID)
+		thumbnail.Remove(clientConfig.StoragePath, fileID)
+		imaging.RemoveCached(clientConfig.StoragePath, fileID)
+		storage.DeleteMetadata(r.Context(), clientConfig.StoragePath, fileID)
+		unlock()
 
 		successFiles = append(successFiles, fileID)
 	}
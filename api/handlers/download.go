@@ -1,22 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 
 	"file-server-sofmar/config"
 	"file-server-sofmar/middleware"
 	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/tracing"
 
 	"github.com/gorilla/mux"
 )
 
-// DownloadFile maneja la descarga de archivos
+// DownloadFile maneja la descarga de archivos (GET y HEAD). El parámetro
+// disposition=inline permite mostrar PDFs e imágenes en el navegador.
 func DownloadFile(w http.ResponseWriter, r *http.Request) {
 	// Obtener fileId de la URL
 	vars := mux.Vars(r)
@@ -26,6 +27,16 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	disposition := r.URL.Query().Get("disposition")
+	switch disposition {
+	case "":
+		disposition = "attachment"
+	case "inline", "attachment":
+	default:
+		sendErrorResponse(w, "disposition debe ser inline o attachment", http.StatusBadRequest)
+		return
+	}
+
 	// Obtener client ID del contexto
 	clientID := middleware.GetClientFromContext(r.Context())
 	clientConfig, exists := config.GetClientConfig(clientID)
@@ -35,12 +46,25 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Encontrar archivo por ID
-	fileInfo, err := findFileByID(fileID, clientID, clientConfig.StoragePath)
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
 	if err != nil {
 		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
 		return
 	}
 
+	w.Header().Add("Vary", "X-Client-Id")
+	serveFile(w, r, fileInfo, disposition, clientConfig.CacheControl())
+}
+
+// serveFile envía un archivo almacenado. Content-Length, rangos (incluidos sufijos
+// y multipart/byteranges) y requests condicionales los resuelve http.ServeContent.
+func serveFile(w http.ResponseWriter, r *http.Request, fileInfo *models.FileMetadata, disposition, cacheControl string) {
+	// Archivos pendientes de análisis antivirus o con malware
+	if status, msg := scanBlock(fileInfo); status != 0 {
+		sendErrorResponse(w, msg, status)
+		return
+	}
+
 	// Verificar que el archivo existe
 	if _, err := os.Stat(fileInfo.Path); os.IsNotExist(err) {
 		sendErrorResponse(w, "Archivo no existe en el filesystem", http.StatusNotFound)
@@ -62,160 +86,91 @@ func DownloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Headers para descarga
 	w.Header().Set("Content-Type", fileInfo.MimeType)
-	w.Header().Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileInfo.OriginalName))
-	w.Header().Set("Cache-Control", "public, max-age=31536000") // Cache por 1 año
-
-	// Manejar range requests para streaming
-	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" {
-		handleRangeRequest(w, r, file, stat.Size(), fileInfo.MimeType)
-		return
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, fileInfo.OriginalName, fileInfo.MimeType))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", cacheControl)
+	if etag := fileETag(fileInfo); etag != "" {
+		w.Header().Set("ETag", etag)
 	}
 
-	// Transferir archivo completo
-	w.WriteHeader(http.StatusOK)
-	_, err = io.Copy(w, file)
-	if err != nil {
-		// Log error but can't send response as headers are already sent
-		fmt.Printf("Error streaming file: %v\n", err)
-	}
+	_, span := tracing.Start(r.Context(), "storage.ReadFile", tracing.File(fileInfo.FileID), tracing.Size(stat.Size()))
+	http.ServeContent(w, r, fileInfo.OriginalName, stat.ModTime(), file)
+	tracing.End(span, nil)
 }
 
-// handleRangeRequest maneja requests con Range header para streaming
-func handleRangeRequest(w http.ResponseWriter, r *http.Request, file *os.File, fileSize int64, mimeType string) {
-	rangeHeader := r.Header.Get("Range")
-	
-	// Parse range header (formato: "bytes=start-end")
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-		return
+// contentDisposition arma el header Content-Disposition según RFC 6266, con
+// filename ASCII de respaldo y filename* (RFC 5987) para nombres con acentos.
+// Los tipos que pueden ejecutar scripts en el navegador siempre se descargan.
+func contentDisposition(disposition, filename, mimeType string) string {
+	if disposition == "inline" && activeContentTypes[mimeBase(mimeType)] {
+		disposition = "attachment"
 	}
 
-	rangeSpec := strings.TrimPrefix(rangeHeader, "bytes=")
-	rangeParts := strings.Split(rangeSpec, "-")
-	
-	var start, end int64
-	var err error
-
-	// Parse start
-	if rangeParts[0] != "" {
-		start, err = strconv.ParseInt(rangeParts[0], 10, 64)
-		if err != nil {
-			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-			return
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
 		}
-	} else {
-		start = 0
-	}
+		return r
+	}, filename)
 
-	// Parse end
-	if len(rangeParts) > 1 && rangeParts[1] != "" {
-		end, err = strconv.ParseInt(rangeParts[1], 10, 64)
-		if err != nil {
-			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-			return
-		}
-	} else {
-		end = fileSize - 1
+	value := fmt.Sprintf(`%s; filename="%s"`, disposition, fallback)
+	if fallback != filename {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
 	}
+	return value
+}
 
-	// Validar range
-	if start < 0 || start >= fileSize || end < start || end >= fileSize {
-		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-		return
-	}
+// activeContentTypes son los tipos que no se sirven inline para evitar XSS
+var activeContentTypes = map[string]bool{
+	"text/html":              true,
+	"application/xhtml+xml":  true,
+	"image/svg+xml":          true,
+	"text/xml":               true,
+	"application/xml":        true,
+	"text/javascript":        true,
+	"application/javascript": true,
+}
 
-	// Mover a la posición inicial
-	_, err = file.Seek(start, 0)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+// mimeBase retorna el tipo MIME sin parámetros (charset, etc.)
+func mimeBase(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
 	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
 
-	// Headers para partial content
-	contentLength := end - start + 1
-	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
-	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
-	w.Header().Set("Content-Type", mimeType)
-	w.WriteHeader(http.StatusPartialContent)
-
-	// Transferir el rango solicitado
-	_, err = io.CopyN(w, file, contentLength)
-	if err != nil {
-		fmt.Printf("Error streaming range: %v\n", err)
+// encodeRFC5987 codifica en porcentaje los bytes UTF-8 que no son attr-char
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
 	}
+	return b.String()
 }
 
-// findFileByID busca un archivo por su ID en el filesystem
-func findFileByID(fileID, clientID, storagePath string) (*models.FileMetadata, error) {
-	// Construir ruta de búsqueda
-	searchPath := filepath.Join("/app", storagePath)
-	
-	// Buscar archivos que empiecen con el fileID
-	files, err := filepath.Glob(filepath.Join(searchPath, fileID+".*"))
-	if err != nil {
-		return nil, err
+// fileETag retorna un ETag fuerte a partir del SHA-256 guardado en la metadata.
+// Archivos anteriores al cálculo de hash no tienen ETag y se validan solo con
+// Last-Modified.
+func fileETag(metadata *models.FileMetadata) string {
+	if metadata.Hash == "" {
+		return ""
 	}
+	return `"` + metadata.Hash + `"`
+}
 
-	if len(files) == 0 {
+// findFileByID busca un archivo por su ID usando el índice en memoria,
+// por lo que funciona para archivos en cualquier subcarpeta
+func findFileByID(ctx context.Context, fileID, clientID string) (*models.FileMetadata, error) {
+	metadata, ok := storage.LookupFile(ctx, clientID, fileID)
+	if !ok {
 		return nil, fmt.Errorf("archivo no encontrado")
 	}
-
-	// Tomar el primer archivo encontrado
-	filePath := files[0]
-	
-	// Obtener información del archivo
-	stat, err := os.Stat(filePath)
-	if err != nil {
-		return nil, err
-	}
-
-	fileName := filepath.Base(filePath)
-	extension := filepath.Ext(fileName)
-	
-	// Intentar extraer el nombre original (remover UUID prefix)
-	originalName := fileName
-	if len(fileName) > 36 && fileName[36] == '.' {
-		// Formato: uuid + extension
-		originalName = "file" + extension
-	}
-
-	// Detectar MIME type basado en la extensión
-	mimeType := "application/octet-stream" // default
-	switch strings.ToLower(extension) {
-	case ".png":
-		mimeType = "image/png"
-	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
-	case ".gif":
-		mimeType = "image/gif"
-	case ".pdf":
-		mimeType = "application/pdf"
-	case ".txt":
-		mimeType = "text/plain"
-	case ".doc", ".docx":
-		mimeType = "application/msword"
-	case ".xls", ".xlsx":
-		mimeType = "application/vnd.ms-excel"
-	case ".zip":
-		mimeType = "application/zip"
-	}
-
-	// Crear metadata básica
-	metadata := &models.FileMetadata{
-		FileID:       fileID,
-		OriginalName: originalName,
-		FileName:     fileName,
-		Client:       clientID,
-		Size:         stat.Size(),
-		Extension:    extension,
-		MimeType:     mimeType,
-		Path:         filePath,
-		URL:          fmt.Sprintf("/static/%s/%s", clientID, fileName),
-	}
-
-	return metadata, nil
-}
\ No newline at end of file
+	return &metadata, nil
+}
@@ -1,11 +1,10 @@
 package handlers
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,6 +12,7 @@ import (
 	"file-server-sofmar/config"
 	"file-server-sofmar/middleware"
 	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
 
 	"github.com/gorilla/mux"
 )
@@ -33,7 +33,7 @@ func ListFiles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verificar que el cliente existe
-	clientConfig, exists := config.GetClientConfig(clientID)
+	_, exists := config.GetClientConfig(clientID)
 	if !exists {
 		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
 		return
@@ -59,8 +59,20 @@ func ListFiles(w http.ResponseWriter, r *http.Request) {
 		order = "desc"
 	}
 
+	// El cursor define su propio orden; offset se ignora cuando hay cursor
+	var cursor *pageCursor
+	if token := query.Get("cursor"); token != "" {
+		c, err := decodeCursor(token)
+		if err != nil || c.Client != clientID {
+			sendErrorResponse(w, "Cursor inválido", http.StatusBadRequest)
+			return
+		}
+		cursor = c
+		sortBy, order = c.SortBy, c.Order
+	}
+
 	// Buscar archivos en el directorio del cliente
-	files, err := scanClientFiles(clientID, clientConfig.StoragePath)
+	files, err := scanClientFiles(r.Context(), clientID)
 	if err != nil {
 		sendErrorResponse(w, "Error al listar archivos: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -79,23 +91,17 @@ func ListFiles(w http.ResponseWriter, r *http.Request) {
 	// Ordenar archivos
 	sortFiles(files, sortBy, order)
 
-	// Aplicar paginación
+	// Aplicar paginación (por cursor si se envía, si no por offset)
 	total := len(files)
-	if offset >= total {
-		files = []models.FileMetadata{}
-	} else {
-		end := offset + limit
-		if end > total {
-			end = total
-		}
-		files = files[offset:end]
-	}
+	files, nextCursor, prevCursor := paginateFiles(files, clientID, sortBy, order, cursor, offset, limit)
 
 	// Preparar respuesta
 	response := models.ListResponse{
-		Success: true,
-		Data:    files,
-		Count:   total,
+		Success:    true,
+		Data:       files,
+		Count:      total,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -104,110 +110,10 @@ func ListFiles(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// scanClientFiles escanea el directorio de un cliente y retorna metadata de archivos
-func scanClientFiles(clientID, storagePath string) ([]models.FileMetadata, error) {
-	searchPath := filepath.Join("/app", storagePath)
-	
-	// Verificar que el directorio existe
-	if _, err := os.Stat(searchPath); os.IsNotExist(err) {
-		// Directorio no existe, retornar lista vacía
-		return []models.FileMetadata{}, nil
-	}
-
-	var files []models.FileMetadata
-
-	// Caminar por el directorio
-	err := filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Saltear directorios
-		if info.IsDir() {
-			return nil
-		}
-
-		// Extraer fileID del nombre del archivo
-		fileName := info.Name()
-		fileID := extractFileID(fileName)
-		
-		// Detectar subcarpeta: obtener la ruta relativa desde el directorio del cliente
-		relativePath, _ := filepath.Rel(searchPath, filepath.Dir(path))
-		var folder string
-		var fileURL string
-		
-		if relativePath == "." || relativePath == "" {
-			// Archivo en el directorio raíz del cliente
-			folder = ""
-			fileURL = fmt.Sprintf("/static/%s/%s", clientID, fileName)
-		} else {
-			// Archivo en una subcarpeta
-			folder = relativePath
-			fileURL = fmt.Sprintf("/static/%s/%s/%s", clientID, folder, fileName)
-		}
-		
-		// Crear metadata del archivo
-		metadata := models.FileMetadata{
-			FileID:       fileID,
-			OriginalName: extractOriginalName(fileName),
-			FileName:     fileName,
-			Client:       clientID,
-			Folder:       folder,
-			Size:         info.Size(),
-			MimeType:     getMimeTypeFromExtension(filepath.Ext(fileName)),
-			Extension:    filepath.Ext(fileName),
-			UploadedAt:   info.ModTime(),
-			URL:          fileURL,
-			Path:         path,
-		}
-
-		files = append(files, metadata)
-		return nil
-	})
-
-	return files, err
-}
-
-// extractFileID extrae el UUID del nombre del archivo
-func extractFileID(fileName string) string {
-	// Formato esperado: uuid.extension
-	parts := strings.Split(fileName, ".")
-	if len(parts) > 0 && len(parts[0]) == 36 {
-		return parts[0]
-	}
-	// Fallback: usar el nombre completo sin extensión
-	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
-}
-
-// extractOriginalName intenta extraer el nombre original del archivo
-func extractOriginalName(fileName string) string {
-	// Por ahora retornamos el nombre actual
-	// En una implementación más avanzada, esto vendría de una base de datos
-	return fileName
-}
-
-// getMimeTypeFromExtension obtiene el MIME type de una extensión
-func getMimeTypeFromExtension(ext string) string {
-	ext = strings.ToLower(ext)
-	mimeTypes := map[string]string{
-		".pdf":  "application/pdf",
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".png":  "image/png",
-		".gif":  "image/gif",
-		".txt":  "text/plain",
-		".doc":  "application/msword",
-		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
-		".xls":  "application/vnd.ms-excel",
-		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
-		".zip":  "application/zip",
-		".rar":  "application/x-rar-compressed",
-	}
-
-	if mimeType, exists := mimeTypes[ext]; exists {
-		return mimeType
-	}
-	return "application/octet-stream"
+// scanClientFiles retorna la metadata de los archivos de un cliente desde el
+// índice en memoria (se mantiene actualizado por la API y por storage.WatchIndex)
+func scanClientFiles(ctx context.Context, clientID string) ([]models.FileMetadata, error) {
+	return storage.ClientFiles(ctx, clientID)
 }
 
 // filterFiles filtra archivos por nombre o extensión
@@ -242,28 +148,42 @@ func filterFilesByFolder(files []models.FileMetadata, targetFolder string) []mod
 	return filtered
 }
 
-// sortFiles ordena la lista de archivos
+// sortFiles ordena la lista de archivos. Los empates se resuelven por fileId
+// para que el orden sea estable entre requests (necesario para los cursores).
 func sortFiles(files []models.FileMetadata, sortBy, order string) {
 	sort.Slice(files, func(i, j int) bool {
-		var result bool
-		
-		switch sortBy {
-		case "name":
-			result = files[i].OriginalName < files[j].OriginalName
-		case "size":
-			result = files[i].Size < files[j].Size
-		case "extension":
-			result = files[i].Extension < files[j].Extension
-		case "uploadedAt":
-			fallthrough
-		default:
-			result = files[i].UploadedAt.Before(files[j].UploadedAt)
-		}
+		return compareOrdered(files[i], files[j], sortBy, order) < 0
+	})
+}
 
-		if order == "desc" {
-			result = !result
-		}
+// compareOrdered compara dos archivos según el criterio y el sentido de orden
+func compareOrdered(a, b models.FileMetadata, sortBy, order string) int {
+	result := compareFiles(a, b, sortBy)
+	if order == "desc" {
+		result = -result
+	}
+	return result
+}
 
-		return result
-	})
-}
\ No newline at end of file
+// compareFiles compara dos archivos en orden ascendente por el criterio indicado
+func compareFiles(a, b models.FileMetadata, sortBy string) int {
+	var result int
+
+	switch sortBy {
+	case "name":
+		result = cmp.Compare(a.OriginalName, b.OriginalName)
+	case "size":
+		result = cmp.Compare(a.Size, b.Size)
+	case "extension":
+		result = cmp.Compare(a.Extension, b.Extension)
+	case "uploadedAt":
+		fallthrough
+	default:
+		result = a.UploadedAt.Compare(b.UploadedAt)
+	}
+
+	if result == 0 {
+		result = cmp.Compare(a.FileID, b.FileID)
+	}
+	return result
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/middleware"
+)
+
+// Límites del backoff y bloqueo de login
+const (
+	maxLoginLockout = 24 * time.Hour
+	// Pasado este tiempo sin fallos, el historial de intentos se olvida
+	loginFailureTTL = 24 * time.Hour
+	// Máximo de registros en memoria: un ataque con miles de usuarios o IPs
+	// distintos descarta los registros más viejos en lugar de agotar la memoria
+	maxLoginRecords = 100000
+)
+
+// loginRecord guarda los intentos fallidos de login de un usuario o una IP
+type loginRecord struct {
+	Key          string    `json:"key"`
+	Failures     int       `json:"failures"`
+	Lockouts     int       `json:"lockouts"`
+	LastFailure  time.Time `json:"lastFailure"`
+	BlockedUntil time.Time `json:"blockedUntil"`
+}
+
+// loginGuard registra intentos fallidos por usuario y por IP
+type loginGuard struct {
+	mu      sync.Mutex
+	records map[string]*loginRecord
+}
+
+var guard = newLoginGuard()
+
+// newLoginGuard crea un registro de intentos que descarta periódicamente los vencidos
+func newLoginGuard() *loginGuard {
+	g := &loginGuard{records: make(map[string]*loginRecord)}
+	go g.cleanup(time.Minute)
+	return g
+}
+
+// cleanup elimina los registros vencidos, que de otro modo solo se descartan
+// al consultar la misma clave
+func (g *loginGuard) cleanup(interval time.Duration) {
+	for range time.Tick(interval) {
+		g.sweep(time.Now())
+	}
+}
+
+// sweep elimina los registros vencidos y retorna cuántos eliminó
+func (g *loginGuard) sweep(now time.Time) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	removed := 0
+	for key, record := range g.records {
+		if record.expired(now) {
+			delete(g.records, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// expired indica si un registro se puede olvidar: no está bloqueado y su
+// último fallo es anterior a loginFailureTTL
+func (r *loginRecord) expired(now time.Time) bool {
+	return now.Sub(r.LastFailure) > loginFailureTTL && !r.BlockedUntil.After(now)
+}
+
+func userKey(username string) string { return "user:" + username }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// blocked retorna cuánto falta para que se permita un nuevo intento
+// para el usuario o la IP (0 si se permite)
+func (g *loginGuard) blocked(username, ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{userKey(username), ipKey(ip)} {
+		record := g.lookup(key, now)
+		if record != nil && record.BlockedUntil.After(now) {
+			wait = max(wait, record.BlockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// fail registra un intento fallido. Antes del umbral aplica un backoff
+// exponencial (1s, 2s, 4s...); al alcanzarlo bloquea por la duración configurada,
+// que se duplica con cada bloqueo sucesivo. Retorna el bloqueo aplicado (0 si no hubo).
+func (g *loginGuard) fail(username, ip string) time.Duration {
+	cfg := config.Load()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	var locked time.Duration
+	for _, key := range []string{userKey(username), ipKey(ip)} {
+		record := g.lookup(key, now)
+		if record == nil {
+			if len(g.records) >= maxLoginRecords {
+				g.evict(now)
+			}
+			record = &loginRecord{Key: key}
+			g.records[key] = record
+		}
+		record.Failures++
+		record.LastFailure = now
+
+		if record.Failures >= cfg.LoginMaxFailures {
+			lockout := cfg.LoginLockoutDuration
+			for i := 0; i < record.Lockouts && lockout < maxLoginLockout; i++ {
+				lockout *= 2
+			}
+			lockout = min(lockout, maxLoginLockout)
+			record.BlockedUntil = now.Add(lockout)
+			record.Lockouts++
+			record.Failures = 0
+			locked = max(locked, lockout)
+			continue
+		}
+
+		backoff := min(time.Second<<(record.Failures-1), cfg.LoginLockoutDuration)
+		record.BlockedUntil = now.Add(backoff)
+	}
+	return locked
+}
+
+// succeed limpia el historial de intentos del usuario y de la IP
+func (g *loginGuard) succeed(username, ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.records, userKey(username))
+	delete(g.records, ipKey(ip))
+}
+
+// unlock elimina el bloqueo de una clave. Retorna false si no existía.
+func (g *loginGuard) unlock(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, exists := g.records[key]
+	delete(g.records, key)
+	return exists
+}
+
+// list retorna los registros vigentes ordenados por último fallo
+func (g *loginGuard) list() []loginRecord {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	records := []loginRecord{}
+	for key := range g.records {
+		if record := g.lookup(key, now); record != nil {
+			records = append(records, *record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastFailure.After(records[j].LastFailure)
+	})
+	return records
+}
+
+// lookup retorna el registro de una clave descartando los vencidos. Requiere mu tomado.
+func (g *loginGuard) lookup(key string, now time.Time) *loginRecord {
+	record, ok := g.records[key]
+	if !ok {
+		return nil
+	}
+	if record.expired(now) {
+		delete(g.records, key)
+		return nil
+	}
+	return record
+}
+
+// evict libera lugar cuando se alcanzó maxLoginRecords: elimina los registros
+// vencidos y, si no alcanza, el de fallo más antiguo priorizando los que no
+// están bloqueados. Requiere mu tomado.
+func (g *loginGuard) evict(now time.Time) {
+	var oldest *loginRecord
+	for key, record := range g.records {
+		if record.expired(now) {
+			delete(g.records, key)
+			continue
+		}
+		if oldest == nil || evictBefore(record, oldest, now) {
+			oldest = record
+		}
+	}
+	if len(g.records) >= maxLoginRecords && oldest != nil {
+		delete(g.records, oldest.Key)
+	}
+}
+
+// evictBefore indica si a debe descartarse antes que b
+func evictBefore(a, b *loginRecord, now time.Time) bool {
+	aBlocked, bBlocked := a.BlockedUntil.After(now), b.BlockedUntil.After(now)
+	if aBlocked != bBlocked {
+		return !aBlocked
+	}
+	return a.LastFailure.Before(b.LastFailure)
+}
+
+// UnlockRequest representa una solicitud de desbloqueo de login
+type UnlockRequest struct {
+	Username string `json:"username,omitempty"`
+	IP       string `json:"ip,omitempty"`
+}
+
+// ListLockouts lista los usuarios e IPs con intentos fallidos o bloqueados
+func ListLockouts(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"success": true,
+		"data":    guard.list(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UnlockLogin desbloquea un usuario y/o una IP
+func UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	var req UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "JSON inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" && req.IP == "" {
+		sendErrorResponse(w, "Debe indicar username o ip", http.StatusBadRequest)
+		return
+	}
+
+	unlocked := []string{}
+	if req.Username != "" && guard.unlock(userKey(req.Username)) {
+		unlocked = append(unlocked, userKey(req.Username))
+	}
+	if req.IP != "" && guard.unlock(ipKey(req.IP)) {
+		unlocked = append(unlocked, ipKey(req.IP))
+	}
+	middleware.Logger(r.Context()).Warn("desbloqueo de login", "event", "login_unlocked", "unlocked", unlocked)
+
+	response := map[string]interface{}{
+		"success":  true,
+		"unlocked": unlocked,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
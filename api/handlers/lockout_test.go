@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLoginGuardSweep(t *testing.T) {
+	now := time.Now()
+	g := &loginGuard{records: map[string]*loginRecord{
+		"user:reciente":   {Key: "user:reciente", LastFailure: now.Add(-time.Hour)},
+		"user:vencido":    {Key: "user:vencido", LastFailure: now.Add(-loginFailureTTL - time.Minute)},
+		"ip:bloqueada":    {Key: "ip:bloqueada", LastFailure: now.Add(-loginFailureTTL - time.Minute), BlockedUntil: now.Add(time.Hour)},
+		"ip:desbloqueada": {Key: "ip:desbloqueada", LastFailure: now.Add(-2 * loginFailureTTL), BlockedUntil: now.Add(-time.Hour)},
+	}}
+
+	if removed := g.sweep(now); removed != 2 {
+		t.Errorf("sweep eliminó %d registros, esperado 2", removed)
+	}
+	for _, key := range []string{"user:reciente", "ip:bloqueada"} {
+		if _, ok := g.records[key]; !ok {
+			t.Errorf("sweep eliminó %s, que sigue vigente", key)
+		}
+	}
+}
+
+func TestLoginGuardEvict(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		records []*loginRecord
+		evicted string
+	}{
+		{
+			name: "vencido primero",
+			records: []*loginRecord{
+				{Key: "a", LastFailure: now.Add(-2 * loginFailureTTL)},
+				{Key: "b", LastFailure: now.Add(-time.Hour)},
+			},
+			evicted: "a",
+		},
+		{
+			name: "fallo más antiguo",
+			records: []*loginRecord{
+				{Key: "a", LastFailure: now.Add(-time.Minute)},
+				{Key: "b", LastFailure: now.Add(-time.Hour)},
+			},
+			evicted: "b",
+		},
+		{
+			name: "no bloqueado antes que bloqueado",
+			records: []*loginRecord{
+				{Key: "a", LastFailure: now.Add(-time.Minute)},
+				{Key: "b", LastFailure: now.Add(-time.Hour), BlockedUntil: now.Add(time.Hour)},
+			},
+			evicted: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &loginGuard{records: make(map[string]*loginRecord, maxLoginRecords)}
+			for i := len(tt.records); i < maxLoginRecords; i++ {
+				key := fmt.Sprintf("ip:%d", i)
+				g.records[key] = &loginRecord{Key: key, LastFailure: now, BlockedUntil: now.Add(time.Hour)}
+			}
+			for _, record := range tt.records {
+				g.records[record.Key] = record
+			}
+
+			g.evict(now)
+			if len(g.records) != maxLoginRecords-1 {
+				t.Fatalf("evict dejó %d registros, esperado %d", len(g.records), maxLoginRecords-1)
+			}
+			if _, ok := g.records[tt.evicted]; ok {
+				t.Errorf("evict no eliminó %s", tt.evicted)
+			}
+		})
+	}
+}
+
+func TestLoginGuardCap(t *testing.T) {
+	g := &loginGuard{records: make(map[string]*loginRecord, maxLoginRecords)}
+	now := time.Now()
+	for i := 0; i < maxLoginRecords; i++ {
+		key := fmt.Sprintf("user:u%d", i)
+		g.records[key] = &loginRecord{Key: key, LastFailure: now}
+	}
+
+	g.fail("nuevo", "10.0.0.1")
+	if len(g.records) > maxLoginRecords {
+		t.Errorf("fail superó el máximo: %d registros", len(g.records))
+	}
+	for _, key := range []string{userKey("nuevo"), ipKey("10.0.0.1")} {
+		if _, ok := g.records[key]; !ok {
+			t.Errorf("fail no registró %s", key)
+		}
+	}
+}
@@ -1,17 +1,24 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"file-server-sofmar/config"
+	"file-server-sofmar/filemeta"
+	"file-server-sofmar/fulltext"
 	"file-server-sofmar/middleware"
 	"file-server-sofmar/models"
+	"file-server-sofmar/tracing"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // GetMetadata obtiene la metadata de un archivo específico
@@ -26,14 +33,14 @@ func GetMetadata(w http.ResponseWriter, r *http.Request) {
 
 	// Obtener client ID del contexto
 	clientID := middleware.GetClientFromContext(r.Context())
-	clientConfig, exists := config.GetClientConfig(clientID)
+	_, exists := config.GetClientConfig(clientID)
 	if !exists {
 		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
 		return
 	}
 
 	// Buscar archivo por ID
-	fileInfo, err := findFileByID(fileID, clientID, clientConfig.StoragePath)
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
 	if err != nil {
 		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
 		return
@@ -50,12 +57,14 @@ func GetMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Actualizar información del archivo con datos del filesystem
+	// (la fecha de subida viene de la metadata persistida si existe)
 	fileInfo.Size = stat.Size()
-	fileInfo.UploadedAt = stat.ModTime()
 
-	// Obtener información adicional si está disponible
-	// TODO: Si usamos base de datos, obtener metadata extendida
-	
+	// Archivos subidos antes de extraer metadata embebida: se lee en el momento
+	if fileInfo.Extended == nil && filemeta.Supported(fileInfo.Extension) {
+		fileInfo.Extended = extractMetadata(r.Context(), fileInfo.Path, fileInfo.Extension)
+	}
+
 	// Respuesta con metadata completa
 	response := map[string]interface{}{
 		"success": true,
@@ -67,8 +76,47 @@ func GetMetadata(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// SearchFiles maneja la búsqueda de archivos con filtros avanzados
+// SearchFiles maneja la búsqueda de archivos con filtros avanzados (POST con JSON)
 func SearchFiles(w http.ResponseWriter, r *http.Request) {
+	// Decodificar request de búsqueda
+	var searchReq models.SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&searchReq); err != nil {
+		sendErrorResponse(w, "JSON inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	runSearch(w, r, searchReq)
+}
+
+// SearchFilesQuery maneja la búsqueda vía GET: /search/{client}?q=type:pdf size>5MB
+func SearchFilesQuery(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	searchReq := models.SearchRequest{
+		Query:    query.Get("q"),
+		Content:  query.Get("content"),
+		DateFrom: query.Get("dateFrom"),
+		DateTo:   query.Get("dateTo"),
+		Sort:     query.Get("sort"),
+		Order:    query.Get("order"),
+		Cursor:   query.Get("cursor"),
+	}
+	if types := query.Get("types"); types != "" {
+		searchReq.Types = strings.Split(types, ",")
+	}
+	if tags := query.Get("tags"); tags != "" {
+		searchReq.Tags = strings.Split(tags, ",")
+	}
+	searchReq.MinSize, _ = strconv.ParseInt(query.Get("minSize"), 10, 64)
+	searchReq.MaxSize, _ = strconv.ParseInt(query.Get("maxSize"), 10, 64)
+	searchReq.Limit, _ = strconv.Atoi(query.Get("limit"))
+	searchReq.Offset, _ = strconv.Atoi(query.Get("offset"))
+
+	runSearch(w, r, searchReq)
+}
+
+// runSearch ejecuta una búsqueda sobre los archivos del cliente y escribe la respuesta
+func runSearch(w http.ResponseWriter, r *http.Request, searchReq models.SearchRequest) {
 	// Obtener client ID de la URL
 	vars := mux.Vars(r)
 	clientID := vars["client"]
@@ -88,34 +136,105 @@ func SearchFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Decodificar request de búsqueda
-	var searchReq models.SearchRequest
-	if err := json.NewDecoder(r.Body).Decode(&searchReq); err != nil {
-		sendErrorResponse(w, "JSON inválido: "+err.Error(), http.StatusBadRequest)
+	// Validar request (basta con query, búsqueda por contenido, tags o campos personalizados)
+	if searchReq.Query == "" && searchReq.Content == "" && len(searchReq.Tags) == 0 && len(searchReq.Custom) == 0 {
+		sendErrorResponse(w, "Query de búsqueda requerido", http.StatusBadRequest)
 		return
 	}
 
-	// Validar request
-	if searchReq.Query == "" {
-		sendErrorResponse(w, "Query de búsqueda requerido", http.StatusBadRequest)
-		return
+	// Parsear el lenguaje de consulta. Texto libre con paréntesis o comillas
+	// desbalanceados (ej: "informe (final") se busca literal, como antes de
+	// existir el lenguaje; cualquier otro error se informa.
+	parsed, err := parseQuery(searchReq.Query)
+	if err != nil {
+		if !literalFallback(searchReq.Query, err) {
+			sendErrorResponse(w, "Query inválida: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		middleware.Logger(r.Context()).Debug("query desbalanceada, se busca como texto literal", "query", searchReq.Query, "error", err)
+		parsed = literalQuery(searchReq.Query)
 	}
 
-	// Obtener todos los archivos del cliente
-	files, err := scanClientFiles(clientID, clientConfig.StoragePath)
+	// Obtener los archivos del cliente que cumplen la búsqueda
+	filteredFiles, scores, err := searchMatches(r.Context(), clientID, parsed, searchReq)
 	if err != nil {
 		sendErrorResponse(w, "Error al buscar archivos: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	total := len(filteredFiles)
 
-	// Aplicar filtros de búsqueda
-	filteredFiles := applySearchFilters(files, searchReq)
+	// Ordenar y paginar (sin limit ni cursor se retornan todos los resultados).
+	// Los campos del request tienen prioridad sobre las directivas de la query.
+	sortBy, order, limit := searchReq.Sort, searchReq.Order, searchReq.Limit
+	if sortBy == "" {
+		sortBy = parsed.sort
+	}
+	if order == "" {
+		order = parsed.order
+	}
+	if limit == 0 {
+		limit = parsed.limit
+	}
+	// Con búsqueda por contenido el orden por defecto es por relevancia
+	byRelevance := scores != nil && sortBy == "" && searchReq.Cursor == ""
+	if sortBy == "" {
+		sortBy = "uploadedAt"
+	}
+	if order == "" {
+		order = "desc"
+	}
+
+	var cursor *pageCursor
+	if searchReq.Cursor != "" {
+		c, err := decodeCursor(searchReq.Cursor)
+		if err != nil || c.Client != clientID {
+			sendErrorResponse(w, "Cursor inválido", http.StatusBadRequest)
+			return
+		}
+		cursor = c
+		sortBy, order = c.SortBy, c.Order
+	}
+
+	var nextCursor, prevCursor string
+	if byRelevance {
+		// El puntaje no es una clave estable, así que se pagina solo por offset
+		sortByScore(filteredFiles, scores)
+		if limit > 0 {
+			if limit > 1000 {
+				limit = 100
+			}
+			filteredFiles, _, _ = paginateFiles(filteredFiles, clientID, sortBy, order, nil, searchReq.Offset, limit)
+		}
+	} else {
+		sortFiles(filteredFiles, sortBy, order)
+		if limit > 0 || cursor != nil {
+			if limit <= 0 || limit > 1000 {
+				limit = 100
+			}
+			filteredFiles, nextCursor, prevCursor = paginateFiles(filteredFiles, clientID, sortBy, order, cursor, searchReq.Offset, limit)
+		}
+	}
+
+	// Puntaje y fragmentos resaltados solo para la página retornada
+	var matches map[string]models.ContentMatch
+	if scores != nil {
+		matches = make(map[string]models.ContentMatch, len(filteredFiles))
+		for _, file := range filteredFiles {
+			matches[file.FileID] = models.ContentMatch{
+				Score:    scores[file.FileID],
+				Snippets: fulltext.Snippets(clientConfig.StoragePath, file.FileID, searchReq.Content, 3),
+			}
+		}
+	}
 
 	// Preparar respuesta
 	response := models.ListResponse{
-		Success: true,
-		Data:    filteredFiles,
-		Count:   len(filteredFiles),
+		Success:    true,
+		Data:       filteredFiles,
+		Count:      total,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+		Matches:    matches,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -123,13 +242,64 @@ func SearchFiles(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// searchMatches retorna los archivos del cliente que cumplen la búsqueda, sin
+// ordenar ni paginar. Con búsqueda por contenido también retorna los puntajes.
+func searchMatches(ctx context.Context, clientID string, parsed *parsedQuery, searchReq models.SearchRequest) ([]models.FileMetadata, map[string]float64, error) {
+	// Obtener todos los archivos del cliente
+	files, err := scanClientFiles(ctx, clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Búsqueda por contenido: solo quedan los archivos cuyo texto coincide
+	var scores map[string]float64
+	if searchReq.Content != "" {
+		_, span := tracing.Start(ctx, "fulltext.Search", tracing.Client(clientID))
+		scores = fulltext.Search(clientID, searchReq.Content)
+		span.SetAttributes(attribute.Int("search.matches", len(scores)))
+		span.End()
+		matched := files[:0]
+		for _, file := range files {
+			if _, ok := scores[file.FileID]; ok {
+				matched = append(matched, file)
+			}
+		}
+		files = matched
+	}
+
+	// Aplicar filtros de búsqueda. Los archivos bloqueados por el antivirus no
+	// aparecen, igual que en archive.
+	return downloadable(applySearchFilters(files, parsed, searchReq)), scores, nil
+}
+
+// sortByScore ordena los resultados por relevancia descendente
+func sortByScore(files []models.FileMetadata, scores map[string]float64) {
+	sort.Slice(files, func(i, j int) bool {
+		si, sj := scores[files[i].FileID], scores[files[j].FileID]
+		if si != sj {
+			return si > sj
+		}
+		return files[i].FileID < files[j].FileID
+	})
+}
+
 // applySearchFilters aplica los filtros de búsqueda a la lista de archivos
-func applySearchFilters(files []models.FileMetadata, searchReq models.SearchRequest) []models.FileMetadata {
+func applySearchFilters(files []models.FileMetadata, parsed *parsedQuery, searchReq models.SearchRequest) []models.FileMetadata {
 	var filtered []models.FileMetadata
 
 	for _, file := range files {
-		// Filtro por query (nombre del archivo)
-		if !matchesQuery(file, searchReq.Query) {
+		// Filtro por query (lenguaje de consulta)
+		if !parsed.Match(file) {
+			continue
+		}
+
+		// Filtro por tags (todos obligatorios)
+		if len(searchReq.Tags) > 0 && !matchesTags(file, searchReq.Tags) {
+			continue
+		}
+
+		// Filtro por campos personalizados (igualdad)
+		if !matchesCustom(file, searchReq.Custom) {
 			continue
 		}
 
@@ -175,12 +345,15 @@ func applySearchFilters(files []models.FileMetadata, searchReq models.SearchRequ
 	return filtered
 }
 
-// matchesQuery verifica si un archivo coincide con la query de búsqueda
-func matchesQuery(file models.FileMetadata, query string) bool {
-	query = strings.ToLower(query)
-	return strings.Contains(strings.ToLower(file.OriginalName), query) ||
-		strings.Contains(strings.ToLower(file.FileName), query) ||
-		strings.Contains(strings.ToLower(file.Extension), query)
+// matchesCustom verifica que los campos personalizados coincidan con los indicados
+func matchesCustom(file models.FileMetadata, custom map[string]string) bool {
+	for key, wanted := range custom {
+		value, ok := file.Custom[key]
+		if !ok || !strings.EqualFold(customValueString(value), wanted) {
+			return false
+		}
+	}
+	return true
 }
 
 // matchesTypes verifica si un archivo coincide con los tipos especificados
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/models"
+)
+
+// pageCursor es el contenido de un cursor de paginación. Se entrega al cliente
+// firmado y codificado, por lo que es opaco y no puede manipularse.
+type pageCursor struct {
+	Client    string `json:"c"`
+	SortBy    string `json:"s"`
+	Order     string `json:"o"`
+	Key       string `json:"k"`  // valor de la clave de orden del elemento ancla
+	FileID    string `json:"id"` // desempate para claves iguales
+	Direction string `json:"d"`  // "next" o "prev"
+}
+
+// encodeCursor serializa y firma un cursor
+func encodeCursor(c pageCursor) string {
+	payload, _ := json.Marshal(c)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signCursor(encoded)
+}
+
+// decodeCursor valida la firma de un cursor y lo deserializa
+func decodeCursor(token string) (*pageCursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("cursor mal formado")
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(signCursor(parts[0]))) {
+		return nil, fmt.Errorf("firma de cursor inválida")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("cursor mal formado")
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, fmt.Errorf("cursor mal formado")
+	}
+	if c.Direction != "next" && c.Direction != "prev" {
+		return nil, fmt.Errorf("dirección de cursor inválida")
+	}
+	return &c, nil
+}
+
+// signCursor calcula la firma HMAC de un cursor con el secreto del servidor
+func signCursor(encoded string) string {
+	cfg := config.Load()
+	mac := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sortKey retorna la clave de orden de un archivo como string para guardarla en un cursor
+func sortKey(file models.FileMetadata, sortBy string) string {
+	switch sortBy {
+	case "name":
+		return file.OriginalName
+	case "size":
+		return strconv.FormatInt(file.Size, 10)
+	case "extension":
+		return file.Extension
+	default:
+		return file.UploadedAt.Format(time.RFC3339Nano)
+	}
+}
+
+// cursorAnchor reconstruye un archivo "ancla" a partir de un cursor para poder compararlo
+func cursorAnchor(c *pageCursor) models.FileMetadata {
+	anchor := models.FileMetadata{FileID: c.FileID}
+	switch c.SortBy {
+	case "name":
+		anchor.OriginalName = c.Key
+	case "size":
+		anchor.Size, _ = strconv.ParseInt(c.Key, 10, 64)
+	case "extension":
+		anchor.Extension = c.Key
+	default:
+		anchor.UploadedAt, _ = time.Parse(time.RFC3339Nano, c.Key)
+	}
+	return anchor
+}
+
+// paginateFiles corta una página de una lista ya ordenada. Si hay cursor, la
+// página empieza (o termina) en el elemento ancla; si no, se usa offset.
+// Retorna la página y los cursores hacia la página siguiente y la anterior.
+func paginateFiles(files []models.FileMetadata, clientID, sortBy, order string, cursor *pageCursor, offset, limit int) ([]models.FileMetadata, string, string) {
+	total := len(files)
+	start, end := 0, 0
+
+	switch {
+	case cursor != nil && cursor.Direction == "next":
+		anchor := cursorAnchor(cursor)
+		start = total
+		for i, file := range files {
+			if compareOrdered(file, anchor, sortBy, order) > 0 {
+				start = i
+				break
+			}
+		}
+		end = min(start+limit, total)
+	case cursor != nil && cursor.Direction == "prev":
+		anchor := cursorAnchor(cursor)
+		end = total
+		for i, file := range files {
+			if compareOrdered(file, anchor, sortBy, order) >= 0 {
+				end = i
+				break
+			}
+		}
+		start = max(end-limit, 0)
+	default:
+		start = min(max(offset, 0), total)
+		end = min(start+limit, total)
+	}
+
+	page := files[start:end]
+
+	var next, prev string
+	if end < total && end > 0 {
+		last := files[end-1]
+		next = encodeCursor(pageCursor{
+			Client: clientID, SortBy: sortBy, Order: order,
+			Key: sortKey(last, sortBy), FileID: last.FileID, Direction: "next",
+		})
+	}
+	if start > 0 && start < total {
+		first := files[start]
+		prev = encodeCursor(pageCursor{
+			Client: clientID, SortBy: sortBy, Order: order,
+			Key: sortKey(first, sortBy), FileID: first.FileID, Direction: "prev",
+		})
+	}
+
+	return page, next, prev
+}
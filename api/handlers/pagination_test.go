@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"file-server-sofmar/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor pageCursor
+	}{
+		{"por fecha", pageCursor{Client: "lobeck", SortBy: "uploadedAt", Order: "desc", Key: "2024-01-02T03:04:05.123Z", FileID: "abc", Direction: "next"}},
+		{"por nombre", pageCursor{Client: "gaesa", SortBy: "name", Order: "asc", Key: "factura ñ.pdf", FileID: "f1", Direction: "prev"}},
+		{"por tamaño", pageCursor{Client: "shared", SortBy: "size", Order: "asc", Key: "1024", FileID: "x", Direction: "next"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := encodeCursor(tt.cursor)
+			got, err := decodeCursor(token)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q): %v", token, err)
+			}
+			if *got != tt.cursor {
+				t.Errorf("decodeCursor = %+v, esperado %+v", *got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsTampered(t *testing.T) {
+	valid := encodeCursor(pageCursor{Client: "lobeck", SortBy: "name", Order: "asc", Key: "a.pdf", FileID: "a", Direction: "next"})
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	// Payload modificado y firmado con otro secreto, o reutilizando la firma original
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"c":"gaesa","s":"name","o":"asc","k":"a.pdf","id":"a","d":"next"}`))
+	// Dirección inválida con firma válida: solo se detecta al deserializar
+	badDirection := base64.RawURLEncoding.EncodeToString([]byte(`{"c":"lobeck","d":"sideways"}`))
+	notJSON := base64.RawURLEncoding.EncodeToString([]byte("no es json"))
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"vacío", "", "mal formado"},
+		{"sin firma", payload, "mal formado"},
+		{"partes de más", valid + ".x", "mal formado"},
+		{"firma vacía", payload + ".", "firma"},
+		{"firma alterada", payload + "." + flipLast(signature), "firma"},
+		{"payload alterado", flipLast(payload) + "." + signature, "firma"},
+		{"payload de otro cliente con firma original", forged + "." + signature, "firma"},
+		{"dirección inválida", badDirection + "." + signCursor(badDirection), "dirección"},
+		{"payload no JSON", notJSON + "." + signCursor(notJSON), "mal formado"},
+		{"base64 inválido", "***." + signCursor("***"), "mal formado"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.token)
+			if err == nil {
+				t.Fatalf("decodeCursor(%q) = %+v, se esperaba error", tt.token, got)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeCursor(%q) error = %q, esperado que contenga %q", tt.token, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginateFilesCursors(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var files []models.FileMetadata
+	for i := 0; i < 5; i++ {
+		files = append(files, models.FileMetadata{
+			FileID:     fmt.Sprintf("f%d", i),
+			UploadedAt: base.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	page, next, prev := paginateFiles(files, "lobeck", "uploadedAt", "asc", nil, 0, 2)
+	if ids(page) != "f0,f1" || prev != "" || next == "" {
+		t.Fatalf("primera página = %s (next %q, prev %q)", ids(page), next, prev)
+	}
+
+	cursor, err := decodeCursor(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, next, prev = paginateFiles(files, "lobeck", "uploadedAt", "asc", cursor, 0, 2)
+	if ids(page) != "f2,f3" || next == "" || prev == "" {
+		t.Fatalf("segunda página = %s (next %q, prev %q)", ids(page), next, prev)
+	}
+
+	cursor, err = decodeCursor(prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _, prev = paginateFiles(files, "lobeck", "uploadedAt", "asc", cursor, 0, 2)
+	if ids(page) != "f0,f1" || prev != "" {
+		t.Fatalf("página anterior = %s (prev %q)", ids(page), prev)
+	}
+}
+
+// flipLast cambia el último carácter de un string base64 por otro válido
+func flipLast(s string) string {
+	last := s[len(s)-1]
+	replacement := byte('A')
+	if last == 'A' {
+		replacement = 'B'
+	}
+	return s[:len(s)-1] + string(replacement)
+}
+
+func ids(files []models.FileMetadata) string {
+	var out []string
+	for _, file := range files {
+		out = append(out, file.FileID)
+	}
+	return strings.Join(out, ",")
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/models"
+	"file-server-sofmar/preview"
+	"file-server-sofmar/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPreviewLines = 50
+	maxPreviewLines     = 1000
+)
+
+// PreviewFile devuelve una vista previa de un archivo sin descargarlo completo:
+// las primeras líneas de archivos de texto, las primeras filas de CSV y planillas
+// XLSX como tabla JSON, o una página de un PDF como PNG.
+// Parámetros: lines, rows, sheet, page y format=text|table (solo CSV/TSV).
+func PreviewFile(w http.ResponseWriter, r *http.Request) {
+	fileID := mux.Vars(r)["fileId"]
+	query := r.URL.Query()
+
+	// Obtener client ID del contexto
+	clientID := middleware.GetClientFromContext(r.Context())
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
+		return
+	}
+
+	lines, err := previewLimit(query.Get("lines"), "lines")
+	if err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := previewLimit(query.Get("rows"), "rows")
+	if err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format != "" && format != "text" && format != "table" {
+		sendErrorResponse(w, "format debe ser text o table", http.StatusBadRequest)
+		return
+	}
+
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
+	if err != nil {
+		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if status, msg := scanBlock(fileInfo); status != 0 {
+		sendErrorResponse(w, msg, status)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", clientConfig.CacheControl())
+	w.Header().Add("Vary", "X-Client-Id")
+
+	ext := strings.ToLower(fileInfo.Extension)
+	lang, isText := preview.TextLanguage(ext)
+	switch {
+	case (ext == ".csv" || ext == ".tsv" || ext == ".xlsx") && format != "text":
+		previewTable(w, r, fileInfo, rows, query.Get("sheet"))
+	case isText && format != "table":
+		previewText(w, r, fileInfo, lang, lines)
+	case ext == ".pdf" && format == "":
+		previewPDF(w, r, fileInfo, query.Get("page"))
+	default:
+		sendErrorResponse(w, "El archivo no tiene vista previa en ese formato", http.StatusUnsupportedMediaType)
+	}
+}
+
+// previewText responde las primeras líneas con el Content-Type del lenguaje del
+// archivo. HTML, SVG y JavaScript van como text/plain para no ejecutarse.
+func previewText(w http.ResponseWriter, r *http.Request, fileInfo *models.FileMetadata, lang preview.Language, n int) {
+	_, span := tracing.Start(r.Context(), "preview.Text", tracing.File(fileInfo.FileID))
+	lines, truncated, err := preview.Lines(fileInfo.Path, n)
+	tracing.End(span, err)
+	if err != nil {
+		sendErrorResponse(w, "Error leyendo archivo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", lang.ContentType)
+	w.Header().Set("X-Preview-Language", lang.Name)
+	w.Header().Set("X-Preview-Truncated", strconv.FormatBool(truncated))
+	w.WriteHeader(http.StatusOK)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// previewTable responde las primeras filas de un CSV, TSV o XLSX como JSON
+func previewTable(w http.ResponseWriter, r *http.Request, fileInfo *models.FileMetadata, n int, sheet string) {
+	_, span := tracing.Start(r.Context(), "preview.Table", tracing.File(fileInfo.FileID))
+	var table *models.PreviewTable
+	var err error
+	if strings.ToLower(fileInfo.Extension) == ".xlsx" {
+		table, err = preview.XLSX(fileInfo.Path, sheet, n)
+	} else {
+		table, err = preview.CSV(fileInfo.Path, fileInfo.Extension, n)
+	}
+	tracing.End(span, err)
+	if errors.Is(err, preview.ErrSheetNotFound) {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "No se pudo leer la tabla: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    table,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// previewPDF responde una página del PDF renderizada como PNG (solo texto y
+// rectángulos, ver preview.PDFPage)
+func previewPDF(w http.ResponseWriter, r *http.Request, fileInfo *models.FileMetadata, pageParam string) {
+	page := 1
+	if pageParam != "" {
+		var err error
+		if page, err = strconv.Atoi(pageParam); err != nil || page < 1 {
+			sendErrorResponse(w, "page debe ser un número mayor a 0", http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, span := tracing.Start(r.Context(), "preview.PDFPage", tracing.File(fileInfo.FileID))
+	var buf bytes.Buffer
+	pages, err := preview.PDFPage(&buf, fileInfo.Path, page)
+	tracing.End(span, err)
+	if errors.Is(err, preview.ErrPageNotFound) {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "No se pudo renderizar el PDF: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Preview-Pages", strconv.Itoa(pages))
+	if fileInfo.Hash != "" {
+		w.Header().Set("ETag", fmt.Sprintf(`"%s-p%d"`, fileInfo.Hash, page))
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// previewLimit interpreta lines o rows: default 50, máximo 1000
+func previewLimit(value, name string) (int, error) {
+	if value == "" {
+		return defaultPreviewLines, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxPreviewLines {
+		return 0, fmt.Errorf("%s debe ser un número entre 1 y %d", name, maxPreviewLines)
+	}
+	return n, nil
+}
@@ -0,0 +1,625 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"file-server-sofmar/models"
+)
+
+// Lenguaje de consulta para búsquedas. Ejemplo:
+//
+//	name:catalogo* type:pdf size>5MB uploaded:2025-01..2025-06 folder:manuales tag:vigente -draft
+//
+// Los campos personalizados se filtran con custom.<campo>, por ejemplo
+// custom.codigoProyecto:P-120 o custom.monto>1000.
+//
+// Los términos se combinan con AND implícito; se admiten OR, NOT (o el prefijo -)
+// y paréntesis. Las directivas sort:, order: y limit: no filtran, controlan el
+// orden y la paginación del resultado.
+
+// Errores de comillas o paréntesis desbalanceados. En texto libre (sin
+// campos) la búsqueda los tolera y busca el texto literal, ver literalFallback.
+var (
+	errUnclosedQuote   = errors.New("comillas sin cerrar")
+	errUnclosedParen   = errors.New("falta cerrar paréntesis")
+	errUnexpectedParen = errors.New("paréntesis de cierre inesperado")
+)
+
+// queryNode es un nodo del AST de filtros
+type queryNode interface {
+	match(file models.FileMetadata) bool
+}
+
+type andNode struct{ children []queryNode }
+type orNode struct{ children []queryNode }
+type notNode struct{ child queryNode }
+
+// predicateNode es una condición sobre un campo del archivo
+type predicateNode struct {
+	fn func(file models.FileMetadata) bool
+}
+
+func (n andNode) match(file models.FileMetadata) bool {
+	for _, child := range n.children {
+		if !child.match(file) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n orNode) match(file models.FileMetadata) bool {
+	for _, child := range n.children {
+		if child.match(file) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n notNode) match(file models.FileMetadata) bool { return !n.child.match(file) }
+
+func (n predicateNode) match(file models.FileMetadata) bool { return n.fn(file) }
+
+// parsedQuery es el resultado de parsear una consulta
+type parsedQuery struct {
+	root  queryNode // nil si la consulta solo tiene directivas
+	sort  string
+	order string
+	limit int
+}
+
+// Match indica si un archivo cumple los filtros de la consulta
+func (q *parsedQuery) Match(file models.FileMetadata) bool {
+	return q.root == nil || q.root.match(file)
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type queryToken struct {
+	kind  tokenKind
+	value string
+}
+
+// tokenizeQuery separa la consulta en términos, operadores y paréntesis
+func tokenizeQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && runes[i+1] != ')':
+			tokens = append(tokens, queryToken{kind: tokenNot})
+			i++
+		default:
+			var word strings.Builder
+			quoted := false
+			for i < len(runes) {
+				r = runes[i]
+				if r == '"' {
+					// Las comillas agrupan valores con espacios: name:"catalogo 2025"
+					end := i + 1
+					for end < len(runes) && runes[end] != '"' {
+						end++
+					}
+					if end >= len(runes) {
+						return nil, errUnclosedQuote
+					}
+					word.WriteString(string(runes[i+1 : end]))
+					quoted = true
+					i = end + 1
+					continue
+				}
+				if unicode.IsSpace(r) || r == ')' || r == '(' {
+					break
+				}
+				word.WriteRune(r)
+				i++
+			}
+
+			value := word.String()
+			switch {
+			case !quoted && value == "AND":
+				tokens = append(tokens, queryToken{kind: tokenAnd})
+			case !quoted && value == "OR":
+				tokens = append(tokens, queryToken{kind: tokenOr})
+			case !quoted && value == "NOT":
+				tokens = append(tokens, queryToken{kind: tokenNot})
+			default:
+				tokens = append(tokens, queryToken{kind: tokenTerm, value: value})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// literalFallback indica si una consulta que no se pudo parsear se busca como
+// texto literal: solo texto libre con comillas o paréntesis desbalanceados
+// (ej: "informe (final"). Campos desconocidos, valores o directivas inválidos
+// son errores del usuario y se informan.
+func literalFallback(input string, err error) bool {
+	if !errors.Is(err, errUnclosedQuote) && !errors.Is(err, errUnclosedParen) && !errors.Is(err, errUnexpectedParen) {
+		return false
+	}
+	return !strings.ContainsAny(input, ":<>=")
+}
+
+// literalQuery arma una consulta que busca el texto completo, sin interpretar
+// operadores, en el nombre original, el nombre en disco o la extensión
+func literalQuery(input string) *parsedQuery {
+	needle := strings.ToLower(input)
+	return &parsedQuery{root: predicateNode{fn: func(file models.FileMetadata) bool {
+		return strings.Contains(strings.ToLower(file.OriginalName), needle) ||
+			strings.Contains(strings.ToLower(file.FileName), needle) ||
+			strings.Contains(strings.ToLower(file.Extension), needle)
+	}}}
+}
+
+// queryParser es un parser descendente recursivo sobre los tokens
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	result *parsedQuery
+}
+
+// parseQuery parsea una consulta del lenguaje de búsqueda
+func parseQuery(input string) (*parsedQuery, error) {
+	tokens, err := tokenizeQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens, result: &parsedQuery{}}
+	if len(tokens) == 0 {
+		return p.result, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errUnexpectedParen
+	}
+	p.result.root = root
+	return p.result, nil
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// parseOr: and ("OR" and)*
+func (p *queryParser) parseOr() (queryNode, error) {
+	var children []queryNode
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			children = append(children, node)
+		}
+
+		tok := p.peek()
+		if tok == nil || tok.kind != tokenOr {
+			break
+		}
+		p.pos++
+	}
+	return combine(children, func(c []queryNode) queryNode { return orNode{children: c} }), nil
+}
+
+// parseAnd: unary (["AND"] unary)*
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var children []queryNode
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind == tokenOr || tok.kind == tokenRParen {
+			break
+		}
+		if tok.kind == tokenAnd {
+			p.pos++
+			continue
+		}
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			children = append(children, node)
+		}
+	}
+	return combine(children, func(c []queryNode) queryNode { return andNode{children: c} }), nil
+}
+
+// parseUnary: ("NOT" | "-") unary | "(" or ")" | término
+func (p *queryParser) parseUnary() (queryNode, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, fmt.Errorf("consulta incompleta")
+	}
+	p.pos++
+
+	switch tok.kind {
+	case tokenNot:
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return nil, fmt.Errorf("NOT sin condición")
+		}
+		return notNode{child: child}, nil
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != tokenRParen {
+			return nil, errUnclosedParen
+		}
+		p.pos++
+		return node, nil
+	case tokenTerm:
+		return p.parseTerm(tok.value)
+	default:
+		return nil, fmt.Errorf("operador inesperado")
+	}
+}
+
+// combine arma un nodo AND/OR evitando anidar listas de un solo elemento
+func combine(children []queryNode, build func([]queryNode) queryNode) queryNode {
+	switch len(children) {
+	case 0:
+		return nil
+	case 1:
+		return children[0]
+	default:
+		return build(children)
+	}
+}
+
+// splitTerm separa "campo<op>valor". Retorna campo vacío si el término es texto libre.
+func splitTerm(term string) (field, op, value string) {
+	idx := strings.IndexAny(term, ":<>=")
+	if idx <= 0 {
+		return "", "", term
+	}
+
+	// El nombre del campo conserva mayúsculas para las claves de custom.<campo>
+	field = term[:idx]
+	for i, r := range field {
+		if !unicode.IsLetter(r) && (i == 0 || (!unicode.IsDigit(r) && r != '.' && r != '_')) {
+			return "", "", term
+		}
+	}
+
+	rest := term[idx:]
+	for _, candidate := range []string{">=", "<=", ":", ">", "<", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			return field, candidate, rest[len(candidate):]
+		}
+	}
+	return "", "", term
+}
+
+// parseTerm convierte un término en un predicado (o aplica una directiva)
+func (p *queryParser) parseTerm(term string) (queryNode, error) {
+	field, op, value := splitTerm(term)
+	if key, ok := strings.CutPrefix(field, "custom."); ok && key != "" {
+		if value == "" {
+			return nil, fmt.Errorf("falta valor para %s", field)
+		}
+		return customPredicate(key, op, value), nil
+	}
+	field = strings.ToLower(field)
+
+	switch field {
+	case "":
+		return namePredicate(value)
+	case "sort":
+		value = strings.ToLower(value)
+		if strings.HasPrefix(value, "-") {
+			p.result.order = "desc"
+			value = strings.TrimPrefix(value, "-")
+		}
+		switch value {
+		case "name", "size", "extension", "uploadedat":
+			if value == "uploadedat" {
+				value = "uploadedAt"
+			}
+			p.result.sort = value
+		case "uploaded", "date":
+			p.result.sort = "uploadedAt"
+		default:
+			return nil, fmt.Errorf("orden no soportado: %s", value)
+		}
+		return nil, nil
+	case "order":
+		value = strings.ToLower(value)
+		if value != "asc" && value != "desc" {
+			return nil, fmt.Errorf("order debe ser asc o desc")
+		}
+		p.result.order = value
+		return nil, nil
+	case "limit":
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit inválido: %s", value)
+		}
+		p.result.limit = limit
+		return nil, nil
+	}
+
+	if value == "" {
+		return nil, fmt.Errorf("falta valor para %s", field)
+	}
+
+	switch field {
+	case "name":
+		if op != ":" && op != "=" {
+			return nil, fmt.Errorf("name solo admite ':'")
+		}
+		return namePredicate(value)
+	case "type", "ext":
+		if op != ":" && op != "=" {
+			return nil, fmt.Errorf("%s solo admite ':'", field)
+		}
+		return typePredicate(value), nil
+	case "folder":
+		if op != ":" && op != "=" {
+			return nil, fmt.Errorf("folder solo admite ':'")
+		}
+		return folderPredicate(value)
+	case "size":
+		return rangePredicate(op, value, parseSizeBounds, func(file models.FileMetadata) int64 {
+			return file.Size
+		})
+	case "uploaded", "date":
+		return rangePredicate(op, value, parseDateBounds, func(file models.FileMetadata) int64 {
+			return file.UploadedAt.UnixNano()
+		})
+	case "tag":
+		if op != ":" && op != "=" {
+			return nil, fmt.Errorf("tag solo admite ':'")
+		}
+		return predicateNode{fn: func(file models.FileMetadata) bool {
+			return hasTag(file, value)
+		}}, nil
+	default:
+		return nil, fmt.Errorf("campo desconocido: %s", field)
+	}
+}
+
+// namePredicate compara contra el nombre original y el nombre en disco.
+// Admite texto (contiene), glob (catalogo*) o regex entre barras (/^cat.*\.pdf$/).
+func namePredicate(value string) (queryNode, error) {
+	var matcher func(name string) bool
+
+	switch {
+	case len(value) > 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/"):
+		re, err := regexp.Compile("(?i)" + value[1:len(value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("regex inválida: %v", err)
+		}
+		matcher = re.MatchString
+	case strings.ContainsAny(value, "*?["):
+		pattern := strings.ToLower(value)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("patrón inválido: %s", value)
+		}
+		matcher = func(name string) bool {
+			ok, _ := path.Match(pattern, strings.ToLower(name))
+			return ok
+		}
+	default:
+		needle := strings.ToLower(value)
+		matcher = func(name string) bool {
+			return strings.Contains(strings.ToLower(name), needle)
+		}
+	}
+
+	return predicateNode{fn: func(file models.FileMetadata) bool {
+		return matcher(file.OriginalName) || matcher(file.FileName)
+	}}, nil
+}
+
+// typePredicate acepta MIME types (image/*, application/pdf), extensiones (pdf)
+// o la categoría principal del MIME type (image)
+func typePredicate(value string) queryNode {
+	value = strings.ToLower(value)
+	return predicateNode{fn: func(file models.FileMetadata) bool {
+		if strings.Contains(value, "/") {
+			return matchesTypes(file, []string{value})
+		}
+		ext := strings.TrimPrefix(strings.ToLower(file.Extension), ".")
+		if ext == strings.TrimPrefix(value, ".") {
+			return true
+		}
+		return strings.HasPrefix(strings.ToLower(file.MimeType), value+"/")
+	}}
+}
+
+// folderPredicate acepta la carpeta exacta (incluye subcarpetas) o un glob
+func folderPredicate(value string) (queryNode, error) {
+	value = strings.Trim(value, "/")
+	if strings.ContainsAny(value, "*?[") {
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("patrón inválido: %s", value)
+		}
+		return predicateNode{fn: func(file models.FileMetadata) bool {
+			ok, _ := path.Match(value, file.Folder)
+			return ok
+		}}, nil
+	}
+	return predicateNode{fn: func(file models.FileMetadata) bool {
+		return file.Folder == value || strings.HasPrefix(file.Folder, value+"/")
+	}}, nil
+}
+
+// customPredicate compara un campo personalizado. Con ':' compara por igualdad
+// (o glob); con >, >=, <, <= compara como número si ambos lados lo son y si no como texto.
+func customPredicate(key, op, value string) queryNode {
+	return predicateNode{fn: func(file models.FileMetadata) bool {
+		raw, ok := file.Custom[key]
+		if !ok {
+			return false
+		}
+		actual := customValueString(raw)
+
+		if op == ":" || op == "=" {
+			if strings.ContainsAny(value, "*?[") {
+				matched, _ := path.Match(strings.ToLower(value), strings.ToLower(actual))
+				return matched
+			}
+			return strings.EqualFold(actual, value)
+		}
+
+		var cmpResult int
+		a, errA := strconv.ParseFloat(actual, 64)
+		b, errB := strconv.ParseFloat(value, 64)
+		if errA == nil && errB == nil {
+			cmpResult = cmp.Compare(a, b)
+		} else {
+			cmpResult = strings.Compare(actual, value)
+		}
+
+		switch op {
+		case ">":
+			return cmpResult > 0
+		case ">=":
+			return cmpResult >= 0
+		case "<":
+			return cmpResult < 0
+		case "<=":
+			return cmpResult <= 0
+		}
+		return false
+	}}
+}
+
+// boundsParser convierte un valor en un intervalo [desde, hasta)
+type boundsParser func(value string) (int64, int64, error)
+
+// rangePredicate arma un predicado numérico para los operadores :, =, >, >=, <, <=
+// y para rangos "a..b" (los extremos pueden omitirse)
+func rangePredicate(op, value string, parse boundsParser, get func(models.FileMetadata) int64) (queryNode, error) {
+	if (op == ":" || op == "=") && strings.Contains(value, "..") {
+		parts := strings.SplitN(value, "..", 2)
+		var from, to *int64
+		if parts[0] != "" {
+			start, _, err := parse(parts[0])
+			if err != nil {
+				return nil, err
+			}
+			from = &start
+		}
+		if parts[1] != "" {
+			_, end, err := parse(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			to = &end
+		}
+		return predicateNode{fn: func(file models.FileMetadata) bool {
+			v := get(file)
+			return (from == nil || v >= *from) && (to == nil || v < *to)
+		}}, nil
+	}
+
+	start, end, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var fn func(v int64) bool
+	switch op {
+	case ":", "=":
+		fn = func(v int64) bool { return v >= start && v < end }
+	case ">":
+		fn = func(v int64) bool { return v >= end }
+	case ">=":
+		fn = func(v int64) bool { return v >= start }
+	case "<":
+		fn = func(v int64) bool { return v < start }
+	case "<=":
+		fn = func(v int64) bool { return v < end }
+	default:
+		return nil, fmt.Errorf("operador no soportado: %s", op)
+	}
+
+	return predicateNode{fn: func(file models.FileMetadata) bool { return fn(get(file)) }}, nil
+}
+
+// parseSizeBounds interpreta tamaños como 500, 10KB, 5MB, 1.5GB
+func parseSizeBounds(value string) (int64, int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := float64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSuffix(upper, unit.suffix)
+			multiplier = unit.factor
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(upper, 64)
+	if err != nil || number < 0 {
+		return 0, 0, fmt.Errorf("tamaño inválido: %s", value)
+	}
+	size := int64(number * multiplier)
+	return size, size + 1, nil
+}
+
+// parseDateBounds interpreta fechas YYYY, YYYY-MM o YYYY-MM-DD como el período completo
+func parseDateBounds(value string) (int64, int64, error) {
+	layouts := []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	}
+
+	for _, l := range layouts {
+		if t, err := time.Parse(l.layout, value); err == nil {
+			return t.UnixNano(), l.next(t).UnixNano(), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("fecha inválida: %s (usar YYYY, YYYY-MM o YYYY-MM-DD)", value)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"file-server-sofmar/models"
+)
+
+// queryFiles es el conjunto de archivos sobre el que se evalúan las consultas
+var queryFiles = []models.FileMetadata{
+	{
+		FileID: "cat", OriginalName: "Catalogo 2025.pdf", FileName: "cat.pdf", Extension: ".pdf",
+		MimeType: "application/pdf", Size: 8 << 20, Folder: "manuales/bombas",
+		UploadedAt: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		Tags:       []string{"vigente"}, Custom: map[string]interface{}{"codigoProyecto": "P-120", "monto": 1500.0},
+	},
+	{
+		FileID: "draft", OriginalName: "catalogo draft.docx", FileName: "draft.docx", Extension: ".docx",
+		MimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Size: 200 << 10,
+		Folder: "manuales", UploadedAt: time.Date(2024, 11, 2, 0, 0, 0, 0, time.UTC),
+		Custom: map[string]interface{}{"monto": 900.0},
+	},
+	{
+		FileID: "foto", OriginalName: "foto obra.jpg", FileName: "foto.jpg", Extension: ".jpg",
+		MimeType: "image/jpeg", Size: 2 << 20, Folder: "obras",
+		UploadedAt: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		Tags:       []string{"Vigente", "obra"},
+	},
+}
+
+func TestParseQueryMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // fileIds que cumplen, en el orden de queryFiles
+	}{
+		{"", "cat,draft,foto"},
+		{"catalogo", "cat,draft"},
+		{"CATALOGO", "cat,draft"},
+		{`name:"catalogo 2025"`, "cat"},
+		{"name:catalogo*", "cat,draft"},
+		{`name:/^foto.*\.jpg$/`, "foto"},
+		{"type:pdf", "cat"},
+		{"type:.pdf", "cat"},
+		{"type:image", "foto"},
+		{"type:image/*", "foto"},
+		{"size>5MB", "cat"},
+		{"size<1MB", "draft"},
+		{"size:1MB..5MB", "foto"},
+		{"size:..1MB", "draft"},
+		{"uploaded:2025", "cat,foto"},
+		{"uploaded:2025-01..2025-06", "cat"},
+		{"uploaded>=2025-07", "foto"},
+		{"date<2025", "draft"},
+		{"folder:manuales", "cat,draft"},
+		{"folder:/manuales/", "cat,draft"},
+		{"folder:manuales/*", "cat"},
+		{"tag:vigente", "cat,foto"},
+		{"custom.codigoProyecto:P-120", "cat"},
+		{"custom.codigoProyecto:p-*", "cat"},
+		{"custom.monto>1000", "cat"},
+		{"custom.monto<=900", "draft"},
+		{"catalogo -draft", "cat"},
+		{"catalogo NOT draft", "cat"},
+		{"NOT NOT foto", "foto"},
+		{"type:pdf OR type:jpg", "cat,foto"},
+		{"type:pdf AND tag:vigente", "cat"},
+		{"(type:pdf OR type:docx) folder:manuales -tag:vigente", "draft"},
+		{"((foto))", "foto"},
+		{"sort:-size limit:2", "cat,draft,foto"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			parsed, err := parseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseQuery(%q): %v", tt.query, err)
+			}
+			var got []string
+			for _, file := range queryFiles {
+				if parsed.Match(file) {
+					got = append(got, file.FileID)
+				}
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("parseQuery(%q) coincide con %v, esperado %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryDirectives(t *testing.T) {
+	tests := []struct {
+		query string
+		sort  string
+		order string
+		limit int
+	}{
+		{"sort:name", "name", "", 0},
+		{"sort:-size", "size", "desc", 0},
+		{"sort:uploadedAt order:asc", "uploadedAt", "asc", 0},
+		{"sort:date", "uploadedAt", "", 0},
+		{"catalogo limit:20", "", "", 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			parsed, err := parseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseQuery(%q): %v", tt.query, err)
+			}
+			if parsed.sort != tt.sort || parsed.order != tt.order || parsed.limit != tt.limit {
+				t.Errorf("parseQuery(%q) = sort %q order %q limit %d, esperado %q %q %d",
+					tt.query, parsed.sort, parsed.order, parsed.limit, tt.sort, tt.order, tt.limit)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"(catalogo", "falta cerrar paréntesis"},
+		{"((a OR b) c", "falta cerrar paréntesis"},
+		{"catalogo)", "paréntesis de cierre inesperado"},
+		{"a) OR (b", "paréntesis de cierre inesperado"},
+		{`name:"catalogo`, "comillas sin cerrar"},
+		{"NOT", "consulta incompleta"},
+		{"NOT )", "operador inesperado"},
+		{"NOT sort:name", "NOT sin condición"},
+		{"color:rojo", "campo desconocido"},
+		{"size>", "falta valor"},
+		{"size>grande", "tamaño inválido"},
+		{"uploaded:ayer", "fecha inválida"},
+		{"name:/[/", "regex inválida"},
+		{"name:[a", "patrón inválido"},
+		{"sort:color", "orden no soportado"},
+		{"order:up", "order debe ser"},
+		{"limit:0", "limit inválido"},
+		{"name>a", "name solo admite"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			parsed, err := parseQuery(tt.query)
+			if err == nil {
+				t.Fatalf("parseQuery(%q) = %+v, se esperaba error", tt.query, parsed)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseQuery(%q) error = %q, esperado que contenga %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiteralFallback(t *testing.T) {
+	tests := []struct {
+		query    string
+		fallback bool
+	}{
+		{"informe (final", true},
+		{"informe final)", true},
+		{`informe "final`, true},
+		{"size>5XB", false},
+		{"uploaded:2025-13..x", false},
+		{"tipo:pdf", false},
+		{"sort:color", false},
+		{"type:pdf (informe", false},
+		{"NOT", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := parseQuery(tt.query)
+			if err == nil {
+				t.Fatalf("parseQuery(%q) no retornó error", tt.query)
+			}
+			if got := literalFallback(tt.query, err); got != tt.fallback {
+				t.Errorf("literalFallback(%q, %v) = %v, esperado %v", tt.query, err, got, tt.fallback)
+			}
+		})
+	}
+}
+
+func TestLiteralQuery(t *testing.T) {
+	files := []models.FileMetadata{
+		{FileID: "a", OriginalName: "Informe (final).pdf", FileName: "a.pdf", Extension: ".pdf"},
+		{FileID: "b", OriginalName: "informe.pdf", FileName: "b.pdf", Extension: ".pdf"},
+	}
+	parsed := literalQuery("informe (final")
+	for _, file := range files {
+		if got, want := parsed.Match(file), file.FileID == "a"; got != want {
+			t.Errorf("literalQuery.Match(%s) = %v, esperado %v", file.OriginalName, got, want)
+		}
+	}
+}
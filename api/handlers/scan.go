@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/fulltext"
+	"file-server-sofmar/imaging"
+	"file-server-sofmar/metrics"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/models"
+	"file-server-sofmar/scanner"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/thumbnail"
+	"file-server-sofmar/tracing"
+)
+
+// scanFile analiza con el antivirus un archivo recién guardado si el cliente
+// tiene MalwareScan (retorna nil si no). Si el antivirus no responde, con
+// FailOpen el archivo queda pendiente; sin FailOpen se retorna el error para
+// rechazar la subida.
+func scanFile(ctx context.Context, clientID string, clientConfig config.ClientConfig, fileID, path string) (*models.ScanResult, error) {
+	policy := clientConfig.MalwareScan
+	if policy == nil {
+		return nil, nil
+	}
+
+	_, span := tracing.Start(ctx, "scanner.Scan", tracing.Client(clientID), tracing.File(fileID))
+	result, err := scanner.ScanFile(ctx, path)
+	tracing.End(span, err)
+
+	now := time.Now()
+	switch {
+	case err != nil:
+		metrics.MalwareScans.WithLabelValues(clientID, "error").Inc()
+		middleware.Logger(ctx).Warn("no se pudo analizar el archivo", "file_id", fileID, "fail_open", policy.FailOpen, "error", err)
+		if !policy.FailOpen {
+			return nil, err
+		}
+		return &models.ScanResult{Status: models.ScanPending, Error: err.Error()}, nil
+	case result.Infected:
+		metrics.MalwareScans.WithLabelValues(clientID, "infected").Inc()
+		return &models.ScanResult{Status: models.ScanInfected, Signature: result.Signature, ScannedAt: &now}, nil
+	default:
+		metrics.MalwareScans.WithLabelValues(clientID, "clean").Inc()
+		return &models.ScanResult{Status: models.ScanClean, ScannedAt: &now}, nil
+	}
+}
+
+// rejectInfected pone en cuarentena un archivo recién subido o copiado en el
+// que se detectó malware y responde 422
+func rejectInfected(w http.ResponseWriter, r *http.Request, storagePath string, metadata models.FileMetadata) {
+	middleware.Logger(r.Context()).Warn("malware detectado",
+		"file_id", metadata.FileID, "name", metadata.OriginalName, "signature", metadata.Scan.Signature)
+
+	if err := storage.Quarantine(r.Context(), storagePath, metadata); err != nil {
+		middleware.Logger(r.Context()).Error("error moviendo a cuarentena", "file_id", metadata.FileID, "error", err)
+		os.Remove(metadata.Path)
+	}
+	sendErrorResponse(w, "El archivo contiene malware ("+metadata.Scan.Signature+") y fue puesto en cuarentena", http.StatusUnprocessableEntity)
+}
+
+// scanBlock indica si el contenido de un archivo no se puede servir por el
+// antivirus: 423 si todavía no fue analizado, 403 si tiene malware. Retorna 0
+// si se puede servir. En clientes con MalwareScan un archivo sin análisis
+// (subido antes de habilitarlo o agregado directo en disco) se bloquea hasta
+// que RetryPendingScans lo analice.
+func scanBlock(metadata *models.FileMetadata) (int, string) {
+	if metadata.Scan == nil {
+		if clientConfig, ok := config.GetClientConfig(metadata.Client); ok && clientConfig.MalwareScan != nil {
+			return http.StatusLocked, "El archivo todavía no fue analizado por el antivirus"
+		}
+		return 0, ""
+	}
+	switch metadata.Scan.Status {
+	case models.ScanClean:
+		return 0, ""
+	case models.ScanInfected:
+		return http.StatusForbidden, "El archivo fue marcado como malware"
+	default:
+		return http.StatusLocked, "El archivo todavía no fue analizado por el antivirus"
+	}
+}
+
+// LoadContentIndex carga el índice de contenido de todos los clientes. Los
+// archivos que el antivirus todavía no aprobó se indexan recién al aprobarse
+// (ver applyScan).
+func LoadContentIndex() {
+	for clientID, clientConfig := range config.ClientConfigs {
+		files, err := storage.ClientFiles(context.Background(), clientID)
+		if err != nil {
+			slog.Error("error cargando índice de contenido", "client", clientID, "error", err)
+			continue
+		}
+		fulltext.LoadClient(clientID, clientConfig.StoragePath, downloadable(files))
+	}
+}
+
+// RetryPendingScans analiza cada intervalo los archivos que quedaron pendientes
+// porque el antivirus no respondía al subirlos (clientes con FailOpen) y los
+// que nunca se analizaron
+func RetryPendingScans(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for clientID, clientConfig := range config.ClientConfigs {
+				if clientConfig.MalwareScan == nil {
+					continue
+				}
+				if err := retryClientScans(clientID, clientConfig); err != nil {
+					slog.Warn("reintento de análisis antivirus fallido", "client", clientID, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// retryClientScans reanaliza los archivos pendientes de un cliente. Se corta
+// en el primer error: si el antivirus sigue caído no tiene sentido seguir.
+func retryClientScans(clientID string, clientConfig config.ClientConfig) error {
+	ctx := context.Background()
+	files, err := storage.ClientFiles(ctx, clientID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.Scan != nil && file.Scan.Status != models.ScanPending {
+			continue
+		}
+
+		scan, err := scanFile(ctx, clientID, clientConfig, file.FileID, file.Path)
+		if err != nil {
+			return err
+		}
+		if scan.Status == models.ScanPending {
+			return errors.New(scan.Error)
+		}
+		if err := applyScan(ctx, clientID, clientConfig, file.FileID, scan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyScan guarda el resultado de un reanálisis. El análisis corre sin
+// bloquear el archivo; la metadata se vuelve a leer con el archivo bloqueado
+// para no pisar un PATCH que haya llegado mientras tanto.
+func applyScan(ctx context.Context, clientID string, clientConfig config.ClientConfig, fileID string, scan *models.ScanResult) error {
+	unlock := storage.LockFile(clientID, fileID)
+	defer unlock()
+
+	// El archivo pudo eliminarse mientras se analizaba
+	file, ok := storage.LookupFile(ctx, clientID, fileID)
+	if !ok {
+		return nil
+	}
+	file.Scan = scan
+
+	if scan.Status == models.ScanInfected {
+		slog.Warn("malware detectado", "client", clientID, "file_id", file.FileID, "name", file.OriginalName, "signature", scan.Signature)
+		if err := storage.Quarantine(ctx, clientConfig.StoragePath, file); err != nil {
+			return err
+		}
+		storage.UnindexFile(clientID, file.FileID)
+		fulltext.RemoveFile(clientConfig.StoragePath, clientID, file.FileID)
+		thumbnail.Remove(clientConfig.StoragePath, file.FileID)
+		imaging.RemoveCached(clientConfig.StoragePath, file.FileID)
+		return nil
+	}
+
+	if err := storage.SaveMetadata(ctx, clientConfig.StoragePath, file); err != nil {
+		return err
+	}
+	storage.IndexFile(file)
+	processContent(slog.Default(), clientConfig.StoragePath, file)
+	return nil
+}
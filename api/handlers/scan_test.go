@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/models"
+	"file-server-sofmar/scanner"
+)
+
+// fakeScanner es un antivirus de prueba con un resultado fijo
+type fakeScanner struct {
+	result scanner.Result
+	err    error
+}
+
+func (f fakeScanner) Scan(ctx context.Context, r io.Reader) (scanner.Result, error) {
+	io.Copy(io.Discard, r)
+	return f.result, f.err
+}
+
+func TestScanFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archivo.txt")
+	if err := os.WriteFile(path, []byte("contenido"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { scanner.SetDefault(nil) })
+
+	failOpen := config.ClientConfig{MalwareScan: &config.ScanPolicy{FailOpen: true}}
+	failClosed := config.ClientConfig{MalwareScan: &config.ScanPolicy{FailOpen: false}}
+	down := fakeScanner{err: errors.New("clamd caído")}
+
+	tests := []struct {
+		name    string
+		client  config.ClientConfig
+		scanner scanner.Scanner // nil: antivirus sin configurar
+		status  string          // "" si scanFile no debe analizar
+		wantErr bool
+	}{
+		{"cliente sin análisis", config.ClientConfig{}, down, "", false},
+		{"limpio", failClosed, fakeScanner{}, models.ScanClean, false},
+		{"malware", failClosed, fakeScanner{result: scanner.Result{Infected: true, Signature: "Eicar-Signature"}}, models.ScanInfected, false},
+		{"antivirus caído con failOpen", failOpen, down, models.ScanPending, false},
+		{"antivirus caído sin failOpen", failClosed, down, "", true},
+		{"antivirus sin configurar con failOpen", failOpen, nil, models.ScanPending, false},
+		{"antivirus sin configurar sin failOpen", failClosed, nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner.SetDefault(tt.scanner)
+
+			scan, err := scanFile(context.Background(), "test", tt.client, "file", path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scanFile() error = %v, esperado error: %v", err, tt.wantErr)
+			}
+			if tt.status == "" {
+				if scan != nil {
+					t.Errorf("scanFile() = %+v, esperado nil", scan)
+				}
+				return
+			}
+			if scan == nil || scan.Status != tt.status {
+				t.Fatalf("scanFile() = %+v, esperado status %q", scan, tt.status)
+			}
+			switch tt.status {
+			case models.ScanInfected:
+				if scan.Signature != "Eicar-Signature" || scan.ScannedAt == nil {
+					t.Errorf("scanFile() = %+v, esperado firma y fecha de análisis", scan)
+				}
+			case models.ScanPending:
+				if scan.Error == "" || scan.ScannedAt != nil {
+					t.Errorf("scanFile() = %+v, esperado error y sin fecha de análisis", scan)
+				}
+			}
+		})
+	}
+}
+
+func TestScanBlock(t *testing.T) {
+	// lobeck tiene MalwareScan, shared no
+	tests := []struct {
+		name   string
+		client string
+		scan   *models.ScanResult
+		status int
+	}{
+		{"cliente sin análisis", "shared", nil, 0},
+		{"sin analizar en cliente con análisis", "lobeck", nil, http.StatusLocked},
+		{"limpio", "lobeck", &models.ScanResult{Status: models.ScanClean}, 0},
+		{"pendiente", "lobeck", &models.ScanResult{Status: models.ScanPending}, http.StatusLocked},
+		{"malware", "lobeck", &models.ScanResult{Status: models.ScanInfected}, http.StatusForbidden},
+		{"copiado limpio a cliente sin análisis", "shared", &models.ScanResult{Status: models.ScanClean}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := scanBlock(&models.FileMetadata{Client: tt.client, Scan: tt.scan}); status != tt.status {
+				t.Errorf("scanBlock() = %d, esperado %d", status, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// Vigencia de las URLs firmadas
+const (
+	defaultSignedURLTTL = time.Hour
+	maxSignedURLTTL     = 7 * 24 * time.Hour
+)
+
+// ServeStatic sirve las URLs /static/{client}/{carpeta}/{archivo} que retornan
+// upload y list. El archivo se resuelve con el índice (nunca con el path
+// directo), así que no se pueden leer directorios internos ni salir del cliente.
+// El control de acceso lo hace middleware.StaticAuth.
+func ServeStatic(w http.ResponseWriter, r *http.Request) {
+	clientID := middleware.GetClientFromContext(r.Context())
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Archivo no encontrado", http.StatusNotFound)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/static/"+clientID+"/")
+	folder, fileName := path.Split(rest)
+	folder = strings.TrimSuffix(folder, "/")
+
+	metadata, ok := storage.LookupFile(r.Context(), clientID, storage.FileIDFromName(fileName))
+	if !ok || metadata.FileName != fileName || filepath.ToSlash(metadata.Folder) != folder {
+		sendErrorResponse(w, "Archivo no encontrado", http.StatusNotFound)
+		return
+	}
+
+	cacheControl := clientConfig.CacheControl()
+	if expires, signed := middleware.GetSignedURLExpiry(r.Context()); signed {
+		cacheControl = clientConfig.SignedCacheControl(time.Until(expires))
+	}
+	serveFile(w, r, &metadata, "inline", cacheControl)
+}
+
+// CreateSignedURL genera una URL /static firmada para compartir un archivo de un
+// cliente con autenticación sin exponer el token: GET /signed-url/{fileId}?ttl=1h
+func CreateSignedURL(w http.ResponseWriter, r *http.Request) {
+	fileID := mux.Vars(r)["fileId"]
+
+	clientID := middleware.GetClientFromContext(r.Context())
+	_, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
+		return
+	}
+
+	ttl := defaultSignedURLTTL
+	if value := r.URL.Query().Get("ttl"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxSignedURLTTL {
+			sendErrorResponse(w, "ttl inválido (ej: 30m, 24h; máximo 168h)", http.StatusBadRequest)
+			return
+		}
+		ttl = parsed
+	}
+
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
+	if err != nil {
+		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	response := models.SignedURLResponse{
+		Success:   true,
+		URL:       middleware.SignStaticURL(fileInfo.URL, expires),
+		ExpiresAt: expires,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// Límites para tags y campos personalizados
+const (
+	maxTags         = 50
+	maxTagLength    = 64
+	maxCustomFields = 50
+)
+
+// UpdateMetadata actualiza los tags y campos personalizados de un archivo
+func UpdateMetadata(w http.ResponseWriter, r *http.Request) {
+	// Obtener fileId de la URL
+	vars := mux.Vars(r)
+	fileID := vars["fileId"]
+	if fileID == "" {
+		sendErrorResponse(w, "ID de archivo requerido", http.StatusBadRequest)
+		return
+	}
+
+	// Obtener client ID del contexto
+	clientID := middleware.GetClientFromContext(r.Context())
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
+		return
+	}
+
+	var updateReq models.MetadataUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		sendErrorResponse(w, "JSON inválido: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// La metadata se lee, combina y guarda con el archivo bloqueado para no
+	// perder cambios de otro PATCH o del reanálisis antivirus
+	unlock := storage.LockFile(clientID, fileID)
+	defer unlock()
+
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
+	if err != nil {
+		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if updateReq.Tags != nil {
+		fileInfo.Tags = normalizeTags(*updateReq.Tags)
+	}
+
+	// Combinar campos personalizados (null elimina el campo)
+	if updateReq.Custom != nil {
+		custom := make(map[string]interface{}, len(fileInfo.Custom)+len(updateReq.Custom))
+		for key, value := range fileInfo.Custom {
+			custom[key] = value
+		}
+		for key, value := range updateReq.Custom {
+			if value == nil {
+				delete(custom, key)
+			} else {
+				custom[key] = value
+			}
+		}
+		if len(custom) == 0 {
+			custom = nil
+		}
+		fileInfo.Custom = custom
+	}
+
+	if err := validateTags(fileInfo.Tags); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateCustomFields(clientConfig.MetadataSchema, fileInfo.Custom); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := storage.SaveMetadata(r.Context(), clientConfig.StoragePath, *fileInfo); err != nil {
+		sendErrorResponse(w, "Error al guardar metadata: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	storage.IndexFile(*fileInfo)
+
+	response := map[string]interface{}{
+		"success": true,
+		"data":    fileInfo,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseUploadMetadata lee los campos opcionales "tags" (separados por coma)
+// y "custom" (objeto JSON) de un formulario de subida
+func parseUploadMetadata(r *http.Request, schema []config.CustomField) ([]string, map[string]interface{}, error) {
+	var tags []string
+	if raw := r.FormValue("tags"); raw != "" {
+		tags = normalizeTags(strings.Split(raw, ","))
+	}
+	if err := validateTags(tags); err != nil {
+		return nil, nil, err
+	}
+
+	var custom map[string]interface{}
+	if raw := r.FormValue("custom"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			return nil, nil, fmt.Errorf("campo custom inválido: debe ser un objeto JSON")
+		}
+	}
+	if err := validateCustomFields(schema, custom); err != nil {
+		return nil, nil, err
+	}
+
+	return tags, custom, nil
+}
+
+// normalizeTags limpia espacios y elimina tags vacíos o duplicados
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// validateTags verifica los límites de cantidad y longitud de tags
+func validateTags(tags []string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("máximo %d tags por archivo", maxTags)
+	}
+	for _, tag := range tags {
+		if len(tag) > maxTagLength {
+			return fmt.Errorf("tag demasiado largo: %s", tag)
+		}
+	}
+	return nil
+}
+
+// validateCustomFields valida los campos personalizados contra el esquema del
+// cliente: campos requeridos y tipos. Los campos fuera del esquema son libres.
+func validateCustomFields(schema []config.CustomField, custom map[string]interface{}) error {
+	if len(custom) > maxCustomFields {
+		return fmt.Errorf("máximo %d campos personalizados", maxCustomFields)
+	}
+
+	for key, value := range custom {
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return fmt.Errorf("campo %s: solo se admiten valores string, number o boolean", key)
+		}
+	}
+
+	for _, field := range schema {
+		value, ok := custom[field.Name]
+		if !ok {
+			if field.Required {
+				return fmt.Errorf("campo requerido: %s", field.Name)
+			}
+			continue
+		}
+
+		valid := false
+		switch field.Type {
+		case "string":
+			_, valid = value.(string)
+		case "number":
+			_, valid = value.(float64)
+		case "boolean":
+			_, valid = value.(bool)
+		case "date":
+			if s, ok := value.(string); ok {
+				_, err := time.Parse("2006-01-02", s)
+				valid = err == nil
+			}
+		default:
+			valid = true
+		}
+		if !valid {
+			return fmt.Errorf("campo %s debe ser de tipo %s", field.Name, field.Type)
+		}
+	}
+	return nil
+}
+
+// matchesTags verifica que el archivo tenga todos los tags indicados
+func matchesTags(file models.FileMetadata, tags []string) bool {
+	for _, wanted := range tags {
+		if !hasTag(file, wanted) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTag verifica si un archivo tiene un tag (sin distinguir mayúsculas)
+func hasTag(file models.FileMetadata, tag string) bool {
+	for _, t := range file.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// customValueString convierte un valor personalizado a string para comparar
+func customValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%g", v)
+	case bool:
+		return fmt.Sprintf("%t", v)
+	default:
+		return ""
+	}
+}
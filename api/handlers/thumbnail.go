@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/thumbnail"
+	"file-server-sofmar/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+// GetThumbnail sirve la miniatura de una imagen: /thumbnail/{fileId}?size=small|medium|large.
+// Las imágenes subidas antes de que existieran las miniaturas se procesan al pedirlas.
+func GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	fileID := mux.Vars(r)["fileId"]
+
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = "medium"
+	}
+	if _, ok := storage.ThumbnailSizes[size]; !ok {
+		sendErrorResponse(w, "size debe ser small, medium o large", http.StatusBadRequest)
+		return
+	}
+
+	// Obtener client ID del contexto
+	clientID := middleware.GetClientFromContext(r.Context())
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
+		return
+	}
+
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
+	if err != nil {
+		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if status, msg := scanBlock(fileInfo); status != 0 {
+		sendErrorResponse(w, msg, status)
+		return
+	}
+	if !storage.HasThumbnails(fileInfo.Extension) {
+		sendErrorResponse(w, "El archivo no tiene miniaturas", http.StatusNotFound)
+		return
+	}
+
+	_, span := tracing.Start(r.Context(), "thumbnail.Get", tracing.File(fileID))
+	path, mimeType, err := thumbnail.Get(clientConfig.StoragePath, *fileInfo, size)
+	tracing.End(span, err)
+	if err != nil {
+		sendErrorResponse(w, "Error generando miniatura: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		sendErrorResponse(w, "Error al abrir miniatura: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		sendErrorResponse(w, "Error al obtener información de la miniatura", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", clientConfig.CacheControl())
+	w.Header().Add("Vary", "X-Client-Id")
+	if fileInfo.Hash != "" {
+		w.Header().Set("ETag", `"`+fileInfo.Hash+"-"+size+`"`)
+	}
+	http.ServeContent(w, r, "", stat.ModTime(), file)
+}
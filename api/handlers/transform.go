@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/imaging"
+	"file-server-sofmar/middleware"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/tracing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TransformImage sirve una imagen redimensionada, recortada, rotada o convertida:
+// /transform/{fileId}?preset=card o /transform/{fileId}?w=800&h=600&fit=cover&format=webp&sig=...
+// Solo se aceptan los presets del cliente o parámetros firmados con su clave, para
+// que no se pueda pedir cualquier combinación y llenar el disco o la CPU.
+func TransformImage(w http.ResponseWriter, r *http.Request) {
+	fileID := mux.Vars(r)["fileId"]
+
+	// Obtener client ID del contexto
+	clientID := middleware.GetClientFromContext(r.Context())
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		sendErrorResponse(w, "Cliente no configurado", http.StatusBadRequest)
+		return
+	}
+
+	params, status, msg := transformParams(r.URL.Query(), clientID, fileID, clientConfig.ImagePresets)
+	if status != http.StatusOK {
+		sendErrorResponse(w, msg, status)
+		return
+	}
+	opts, err := imaging.ParseOptions(params)
+	if err != nil {
+		sendErrorResponse(w, "Parámetros inválidos: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fileInfo, err := findFileByID(r.Context(), fileID, clientID)
+	if err != nil {
+		sendErrorResponse(w, "Archivo no encontrado: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if status, msg := scanBlock(fileInfo); status != 0 {
+		sendErrorResponse(w, msg, status)
+		return
+	}
+	if !storage.HasThumbnails(fileInfo.Extension) {
+		sendErrorResponse(w, "El archivo no es una imagen que se pueda transformar", http.StatusUnprocessableEntity)
+		return
+	}
+
+	_, span := tracing.Start(r.Context(), "imaging.Transform", tracing.File(fileID),
+		attribute.String("imaging.params", opts.Canonical()))
+	path, mimeType, err := imaging.Cached(clientConfig.StoragePath, *fileInfo, opts)
+	tracing.End(span, err)
+	if err != nil {
+		sendErrorResponse(w, "Error transformando imagen: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		sendErrorResponse(w, "Error al abrir imagen transformada: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		sendErrorResponse(w, "Error al obtener información de la imagen", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", clientConfig.CacheControl())
+	w.Header().Add("Vary", "X-Client-Id")
+	if fileInfo.Hash != "" {
+		sum := sha256.Sum256([]byte(opts.Canonical()))
+		w.Header().Set("ETag", `"`+fileInfo.Hash+"-"+base64.RawURLEncoding.EncodeToString(sum[:9])+`"`)
+	}
+	http.ServeContent(w, r, "", stat.ModTime(), file)
+}
+
+// transformParams resuelve los parámetros de la transformación: los de un preset
+// del cliente o los de la query, que deben venir firmados. Retorna el status
+// HTTP y el mensaje a usar si la request no es válida.
+func transformParams(query url.Values, clientID, fileID string, presets map[string]string) (url.Values, int, string) {
+	// client se usa para resolver el cliente en <img src>, no es un parámetro
+	query.Del("client")
+
+	if name := query.Get("preset"); name != "" {
+		if len(query) > 1 {
+			return nil, http.StatusBadRequest, "preset no se puede combinar con otros parámetros"
+		}
+		preset, ok := presets[name]
+		if !ok {
+			return nil, http.StatusBadRequest, "Preset no configurado: " + name
+		}
+		params, err := url.ParseQuery(preset)
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Preset mal configurado: " + name
+		}
+		return params, http.StatusOK, ""
+	}
+
+	key := config.Load().ImageSigningKeys[clientID]
+	if key == "" {
+		return nil, http.StatusForbidden, "El cliente solo permite los presets configurados"
+	}
+	signature := query.Get("sig")
+	query.Del("sig")
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(TransformSignature(key, fileID, query))) {
+		return nil, http.StatusForbidden, "Firma de parámetros inválida"
+	}
+	return query, http.StatusOK, ""
+}
+
+// TransformSignature firma los parámetros de una transformación:
+// base64url(HMAC-SHA256(clave, fileId + "?" + parámetros ordenados por nombre))
+func TransformSignature(key, fileID string, params url.Values) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(fileID + "?" + params.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestTransformSignature(t *testing.T) {
+	params := url.Values{"w": {"200"}, "fmt": {"webp"}}
+	signature := TransformSignature("secreto", "abc", params)
+
+	// Los parámetros se firman ordenados: el orden de la query no importa
+	reordered, _ := url.ParseQuery("fmt=webp&w=200")
+	if got := TransformSignature("secreto", "abc", reordered); got != signature {
+		t.Errorf("TransformSignature con parámetros reordenados = %q, esperado %q", got, signature)
+	}
+
+	for name, other := range map[string]string{
+		"otra clave":   TransformSignature("otro", "abc", params),
+		"otro archivo": TransformSignature("secreto", "abd", params),
+		"otro ancho":   TransformSignature("secreto", "abc", url.Values{"w": {"201"}, "fmt": {"webp"}}),
+	} {
+		if other == signature {
+			t.Errorf("TransformSignature con %s retornó la misma firma", name)
+		}
+	}
+}
+
+func TestTransformParams(t *testing.T) {
+	t.Setenv("IMAGE_SIGNING_KEYS", "lobeck:secreto")
+	presets := map[string]string{"thumb": "w=200&h=200&fit=cover", "roto": "w=%zz"}
+	signed := func(fileID, query string) string {
+		params, _ := url.ParseQuery(query)
+		return query + "&sig=" + TransformSignature("secreto", fileID, params)
+	}
+
+	tests := []struct {
+		name   string
+		client string
+		query  string
+		status int
+		want   string // parámetros resultantes (codificados) si status es 200
+	}{
+		{"preset", "shared", "preset=thumb", http.StatusOK, "fit=cover&h=200&w=200"},
+		{"preset con client", "shared", "preset=thumb&client=shared", http.StatusOK, "fit=cover&h=200&w=200"},
+		{"preset con otros parámetros", "lobeck", "preset=thumb&w=50", http.StatusBadRequest, ""},
+		{"preset firmado con otros parámetros", "lobeck", signed("abc", "preset=thumb&w=50"), http.StatusBadRequest, ""},
+		{"preset inexistente", "shared", "preset=grande", http.StatusBadRequest, ""},
+		{"preset mal configurado", "shared", "preset=roto", http.StatusInternalServerError, ""},
+		{"firmado", "lobeck", signed("abc", "w=300&fmt=webp"), http.StatusOK, "fmt=webp&w=300"},
+		{"firmado con client", "lobeck", signed("abc", "w=300") + "&client=lobeck", http.StatusOK, "w=300"},
+		{"sin firma", "lobeck", "w=300", http.StatusForbidden, ""},
+		{"firma de otro archivo", "lobeck", signed("otro", "w=300"), http.StatusForbidden, ""},
+		{"parámetro alterado", "lobeck", signed("abc", "w=300") + "&h=10", http.StatusForbidden, ""},
+		{"ancho alterado", "lobeck", "w=3000&sig=" + TransformSignature("secreto", "abc", url.Values{"w": {"300"}}), http.StatusForbidden, ""},
+		{"firma alterada", "lobeck", signed("abc", "w=300") + "x", http.StatusForbidden, ""},
+		{"cliente sin clave", "shared", "w=300&sig=abc", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params, status, message := transformParams(query, tt.client, "abc", presets)
+			if status != tt.status {
+				t.Fatalf("transformParams(%q) status = %d (%s), esperado %d", tt.query, status, message, tt.status)
+			}
+			if status == http.StatusOK && params.Encode() != tt.want {
+				t.Errorf("transformParams(%q) = %q, esperado %q", tt.query, params.Encode(), tt.want)
+			}
+			if status != http.StatusOK && message == "" {
+				t.Errorf("transformParams(%q) sin mensaje de error", tt.query)
+			}
+		})
+	}
+}
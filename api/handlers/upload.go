@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime"
 	"net/http"
 	"os"
@@ -14,8 +16,13 @@ import (
 	"time"
 
 	"file-server-sofmar/config"
+	"file-server-sofmar/filemeta"
+	"file-server-sofmar/fulltext"
 	"file-server-sofmar/middleware"
 	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/thumbnail"
+	"file-server-sofmar/tracing"
 
 	"github.com/google/uuid"
 )
@@ -67,14 +74,13 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Obtener subcarpeta (opcional)
-	folder := r.FormValue("folder")
-	if folder != "" {
-		// Sanitizar nombre de carpeta (solo permitir letras, números, guiones y guiones bajos)
-		folder = strings.ReplaceAll(folder, " ", "_")
-		folder = strings.ReplaceAll(folder, "..", "") // Prevenir path traversal
-		if len(folder) > 50 {
-			folder = folder[:50] // Limitar longitud
-		}
+	folder := sanitizeFolder(r.FormValue("folder"))
+
+	// Tags y campos personalizados (opcionales)
+	tags, custom, err := parseUploadMetadata(r, clientConfig.MetadataSchema)
+	if err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	// Generar ID único para el archivo
@@ -82,37 +88,38 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 	extension := filepath.Ext(header.Filename)
 	fileName := fileID + extension
 
-	// Crear ruta con subcarpeta si se especifica
-	var uploadPath string
-	if folder != "" {
-		uploadPath = filepath.Join("/app", clientConfig.StoragePath, folder)
-	} else {
-		uploadPath = filepath.Join("/app", clientConfig.StoragePath)
-	}
+	// Ruta completa del archivo (con subcarpeta si se especifica)
+	filePath := filepath.Join(storage.ClientRoot(clientConfig.StoragePath), folder, fileName)
 
-	// Crear directorio (incluyendo subcarpetas) si no existe
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
+	// El archivo se escribe en staging y se mueve a su carpeta recién cuando el
+	// antivirus lo aprueba, para que no se pueda servir sin analizar
+	stagedPath, err := storage.StagingPath(clientConfig.StoragePath, fileName)
+	if err != nil {
 		sendErrorResponse(w, "Error al crear directorio: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Ruta completa del archivo
-	filePath := filepath.Join(uploadPath, fileName)
-
 	// Crear archivo destino
-	destFile, err := os.Create(filePath)
+	destFile, err := os.Create(stagedPath)
 	if err != nil {
 		sendErrorResponse(w, "Error al crear archivo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer destFile.Close()
+	release := storage.TrackPartial(stagedPath)
+	defer release()
 
 	// Copiar contenido con hash calculation
 	hasher := sha256.New()
 	writer := io.MultiWriter(destFile, hasher)
+	_, span := tracing.Start(r.Context(), "storage.WriteFile", tracing.Client(clientID), tracing.File(fileID), tracing.Size(header.Size))
 	_, err = io.Copy(writer, file)
+	tracing.End(span, err)
+	if err == nil {
+		err = destFile.Close()
+	}
 	if err != nil {
-		os.Remove(filePath) // Cleanup en caso de error
+		os.Remove(stagedPath) // Cleanup en caso de error
 		sendErrorResponse(w, "Error al guardar archivo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -120,20 +127,46 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 	// Calcular hash
 	fileHash := hex.EncodeToString(hasher.Sum(nil))
 
+	// Análisis antivirus antes de procesar el contenido (EXIF, miniaturas, texto)
+	scan, err := scanFile(r.Context(), clientID, clientConfig, fileID, stagedPath)
+	if err != nil {
+		os.Remove(stagedPath)
+		sendErrorResponse(w, "No se pudo analizar el archivo con el antivirus: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if scan != nil && scan.Status == models.ScanInfected {
+		rejectInfected(w, r, clientConfig.StoragePath, models.FileMetadata{
+			FileID:       fileID,
+			OriginalName: header.Filename,
+			FileName:     fileName,
+			Client:       clientID,
+			Folder:       folder,
+			Size:         header.Size,
+			Extension:    extension,
+			UploadedAt:   time.Now(),
+			Path:         stagedPath,
+			Hash:         fileHash,
+			Scan:         scan,
+		})
+		return
+	}
+
+	// Política del cliente: borrar la ubicación GPS de las fotos antes de guardarlas
+	if clientConfig.StripGPS {
+		fileHash, err = stripGPS(r.Context(), stagedPath, extension, fileHash)
+		if err != nil {
+			os.Remove(stagedPath)
+			sendErrorResponse(w, "Error al quitar la ubicación de la imagen: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Detectar MIME type
 	mimeType := mime.TypeByExtension(extension)
 	if mimeType == "" {
 		mimeType = "application/octet-stream"
 	}
 
-	// Crear URL con subcarpeta si existe
-	var fileURL string
-	if folder != "" {
-		fileURL = fmt.Sprintf("/static/%s/%s/%s", clientID, folder, fileName)
-	} else {
-		fileURL = fmt.Sprintf("/static/%s/%s", clientID, fileName)
-	}
-
 	// Crear metadata del archivo
 	metadata := models.FileMetadata{
 		FileID:       fileID,
@@ -145,13 +178,38 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 		MimeType:     mimeType,
 		Extension:    extension,
 		UploadedAt:   time.Now(),
-		URL:          fileURL,
+		URL:          storage.FileURL(clientID, folder, fileName),
 		Path:         filePath,
 		Hash:         fileHash,
+		Thumbnails:   storage.ThumbnailURLs(fileID, extension),
+		Tags:         tags,
+		Custom:       custom,
+		Extended:     extractMetadata(r.Context(), stagedPath, extension),
+		Scan:         scan,
 	}
 
-	// TODO: Guardar metadata en base de datos si es necesario
-	// Por ahora solo guardamos en filesystem
+	// Publicar en la carpeta final. Sigue marcado como parcial hasta indexarlo
+	// para que el watcher no lo registre sin la metadata.
+	releasePublished := storage.TrackPartial(filePath)
+	defer releasePublished()
+	if err := storage.Publish(stagedPath, filePath); err != nil {
+		os.Remove(stagedPath)
+		sendErrorResponse(w, "Error al guardar archivo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	release()
+
+	// Guardar metadata junto al archivo para conservar nombre original y hash
+	if err := storage.SaveMetadata(r.Context(), clientConfig.StoragePath, metadata); err != nil {
+		os.Remove(filePath)
+		sendErrorResponse(w, "Error al guardar metadata: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	storage.IndexFile(metadata)
+	releasePublished()
+
+	// Indexar el contenido y generar miniaturas en segundo plano
+	processContent(middleware.Logger(r.Context()), clientConfig.StoragePath, metadata)
 
 	// Respuesta exitosa
 	response := models.UploadResponse{
@@ -159,6 +217,9 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 		Data:    metadata,
 		Message: "Archivo subido exitosamente",
 	}
+	if scan != nil && scan.Status == models.ScanPending {
+		response.Message = "Archivo subido; se podrá descargar cuando el antivirus lo analice"
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -205,12 +266,103 @@ func isAllowedFileType(filename string, allowedTypes []string) bool {
 	return false
 }
 
+// processContent indexa el texto y genera las miniaturas de un archivo en
+// segundo plano. Un archivo que el antivirus todavía no aprobó no se abre: se
+// procesa cuando el reanálisis lo marca limpio.
+func processContent(logger *slog.Logger, storagePath string, metadata models.FileMetadata) {
+	if status, _ := scanBlock(&metadata); status != 0 {
+		return
+	}
+	go indexContent(logger, storagePath, metadata)
+	go generateThumbnails(logger, storagePath, metadata)
+}
+
+// indexContent extrae e indexa el texto de un archivo para la búsqueda por contenido
+func indexContent(logger *slog.Logger, storagePath string, metadata models.FileMetadata) {
+	if err := fulltext.IndexFile(storagePath, metadata); err != nil {
+		logger.Warn("error indexando contenido", "file_id", metadata.FileID, "error", err)
+	}
+}
+
+// generateThumbnails genera las miniaturas de una imagen recién subida
+func generateThumbnails(logger *slog.Logger, storagePath string, metadata models.FileMetadata) {
+	if err := thumbnail.Generate(storagePath, metadata); err != nil {
+		logger.Warn("error generando miniaturas", "file_id", metadata.FileID, "error", err)
+	}
+}
+
+// extractMetadata lee la metadata embebida del archivo (EXIF, propiedades del
+// documento, duración). Un archivo ilegible no impide la subida.
+func extractMetadata(ctx context.Context, path, ext string) *models.ExtendedMetadata {
+	_, span := tracing.Start(ctx, "filemeta.Extract")
+	extended, err := filemeta.Extract(path, ext)
+	tracing.End(span, err)
+	if err != nil {
+		middleware.Logger(ctx).Warn("error extrayendo metadata embebida", "path", path, "error", err)
+	}
+	return extended
+}
+
+// stripGPS borra la ubicación del EXIF de una imagen y, si el archivo cambió,
+// retorna su nuevo hash
+func stripGPS(ctx context.Context, path, ext, hash string) (string, error) {
+	_, span := tracing.Start(ctx, "filemeta.StripGPS")
+	stripped, err := filemeta.StripGPS(path, ext)
+	tracing.End(span, err)
+	if err != nil || !stripped {
+		return hash, err
+	}
+	return hashFile(path)
+}
+
+// hashFile calcula el SHA-256 de un archivo
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// sanitizeFolder limpia el nombre de una subcarpeta recibida del cliente. Se
+// quitan los puntos iniciales de cada segmento: una carpeta como "docs/.meta"
+// sería interna del servidor y sus archivos no se indexarían.
+func sanitizeFolder(folder string) string {
+	if folder == "" {
+		return ""
+	}
+	folder = strings.ReplaceAll(folder, " ", "_")
+	folder = strings.ReplaceAll(folder, "\\", "/")
+
+	// Evitar rutas absolutas, path traversal y carpetas ocultas
+	var segments []string
+	for _, segment := range strings.Split(folder, "/") {
+		segment = strings.TrimLeft(segment, ".")
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	folder = strings.Join(segments, "/")
+
+	if len(folder) > 50 {
+		folder = strings.TrimRight(folder[:50], "/") // Limitar longitud
+	}
+	return folder
+}
+
 // sendErrorResponse envía una respuesta de error estandarizada
 func sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	response := models.ErrorResponse{
-		Success: false,
-		Error:   message,
-		Code:    statusCode,
+		Success:   false,
+		Error:     message,
+		Code:      statusCode,
+		RequestID: w.Header().Get(middleware.RequestIDHeader),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+)
+
+// CacheDir es el directorio oculto donde se guardan las imágenes transformadas
+const CacheDir = ".transforms"
+
+// transforming evita procesar dos veces la misma transformación a la vez
+var transforming sync.Map // ruta en cache -> *sync.Mutex
+
+// cachePath retorna la ruta en cache de una transformación. La clave combina el
+// hash del contenido con los parámetros canónicos, así un archivo reemplazado
+// (mismo fileId, otro contenido) no reutiliza resultados viejos.
+func cachePath(storagePath string, metadata models.FileMetadata, opts Options, ext string) string {
+	sum := sha256.Sum256([]byte(metadata.Hash + "\n" + opts.Canonical()))
+	key := hex.EncodeToString(sum[:8])
+	return filepath.Join(storage.ClientRoot(storagePath), CacheDir, metadata.FileID+"_"+key+ext)
+}
+
+// Cached retorna la ruta y el tipo MIME de la imagen transformada, generándola
+// si todavía no está en cache
+func Cached(storagePath string, metadata models.FileMetadata, opts Options) (string, string, error) {
+	// El formato por defecto depende del original; se resuelve por extensión
+	// para no decodificar la imagen cuando el resultado ya está en cache
+	opts.Format = opts.OutputFormat(formatFromExtension(metadata.Extension))
+	mimeType, ext := ContentType(opts.Format)
+	path := cachePath(storagePath, metadata, opts, ext)
+	if _, err := os.Stat(path); err == nil {
+		return path, mimeType, nil
+	}
+
+	lock, _ := transforming.LoadOrStore(path, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer func() {
+		lock.(*sync.Mutex).Unlock()
+		transforming.Delete(path)
+	}()
+
+	// Otra request pudo generarla mientras se esperaba el lock
+	if _, err := os.Stat(path); err == nil {
+		return path, mimeType, nil
+	}
+
+	src, _, err := Decode(metadata.Path)
+	if err != nil {
+		return "", "", err
+	}
+	if err := write(path, Transform(src, opts), opts); err != nil {
+		return "", "", err
+	}
+	return path, mimeType, nil
+}
+
+// write guarda el resultado en un temporal y lo renombra para no servir archivos a medias
+func write(path string, img *image.RGBA, opts Options) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "transform_*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = Encode(tmp, img, opts.Format, opts.Quality)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RemoveCached borra todas las transformaciones en cache de un archivo
+func RemoveCached(storagePath, fileID string) {
+	matches, _ := filepath.Glob(filepath.Join(storage.ClientRoot(storagePath), CacheDir, fileID+"_*"))
+	for _, path := range matches {
+		os.Remove(path)
+	}
+}
+
+// formatFromExtension retorna el formato de imagen según la extensión del archivo
+func formatFromExtension(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return FormatJPEG
+	case ".png":
+		return FormatPNG
+	case ".webp":
+		return FormatWebP
+	}
+	return ""
+}
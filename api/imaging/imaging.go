@@ -0,0 +1,336 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // decoders registrados para image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels limita el tamaño de las imágenes que se decodifican (evita que una
+// imagen chica en bytes pero enorme en píxeles agote la memoria)
+const MaxPixels = 60_000_000
+
+// MaxSide es el lado máximo de una imagen transformada
+const MaxSide = 4096
+
+// DefaultQuality es la calidad JPEG si no se indica otra
+const DefaultQuality = 82
+
+// Modos de ajuste al redimensionar con ancho y alto
+const (
+	FitContain = "contain" // entra completa en la caja, mantiene proporción
+	FitCover   = "cover"   // cubre la caja, recorta lo que sobra al centro
+	FitFill    = "fill"    // estira a las dimensiones exactas
+)
+
+// Formatos de salida
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// Options son los parámetros de una transformación. Se aplican en orden:
+// recorte, rotación y redimensión.
+type Options struct {
+	Width   int
+	Height  int
+	Fit     string
+	Crop    image.Rectangle // vacío = sin recorte
+	Rotate  int             // 0, 90, 180 o 270 (sentido horario)
+	Format  string          // vacío = según el original
+	Quality int             // solo JPEG; 0 = DefaultQuality
+}
+
+// ParseOptions lee los parámetros w, h, fit, crop, rotate, format y q de una query
+func ParseOptions(query url.Values) (Options, error) {
+	var opts Options
+	var err error
+
+	if opts.Width, err = parseInt(query, "w", 1, MaxSide); err != nil {
+		return opts, err
+	}
+	if opts.Height, err = parseInt(query, "h", 1, MaxSide); err != nil {
+		return opts, err
+	}
+
+	switch fit := query.Get("fit"); fit {
+	case "", FitContain, FitCover, FitFill:
+		opts.Fit = fit
+	default:
+		return opts, fmt.Errorf("fit debe ser contain, cover o fill")
+	}
+
+	if crop := query.Get("crop"); crop != "" {
+		parts := strings.Split(crop, ",")
+		if len(parts) != 4 {
+			return opts, fmt.Errorf("crop debe ser x,y,ancho,alto")
+		}
+		var values [4]int
+		for i, part := range parts {
+			values[i], err = strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || values[i] < 0 {
+				return opts, fmt.Errorf("crop debe ser x,y,ancho,alto")
+			}
+		}
+		if values[2] == 0 || values[3] == 0 {
+			return opts, fmt.Errorf("crop debe tener ancho y alto mayores a cero")
+		}
+		opts.Crop = image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3])
+	}
+
+	if opts.Rotate, err = parseInt(query, "rotate", 0, 270); err != nil {
+		return opts, err
+	}
+	if opts.Rotate%90 != 0 {
+		return opts, fmt.Errorf("rotate debe ser 0, 90, 180 o 270")
+	}
+
+	switch format := strings.ToLower(query.Get("format")); format {
+	case "":
+	case "jpg", FormatJPEG:
+		opts.Format = FormatJPEG
+	case FormatPNG, FormatWebP:
+		opts.Format = format
+	default:
+		return opts, fmt.Errorf("format debe ser jpeg, png o webp")
+	}
+
+	if opts.Quality, err = parseInt(query, "q", 1, 100); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseInt lee un entero opcional de la query validando el rango
+func parseInt(query url.Values, key string, minValue, maxValue int) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minValue || n > maxValue {
+		return 0, fmt.Errorf("%s debe ser un entero entre %d y %d", key, minValue, maxValue)
+	}
+	return n, nil
+}
+
+// Canonical retorna las opciones en un formato estable (mismo resultado para
+// cualquier orden de parámetros), usado como clave de cache
+func (o Options) Canonical() string {
+	query := url.Values{}
+	if o.Width > 0 {
+		query.Set("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		query.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != "" && o.Fit != FitContain {
+		query.Set("fit", o.Fit)
+	}
+	if !o.Crop.Empty() {
+		query.Set("crop", fmt.Sprintf("%d,%d,%d,%d", o.Crop.Min.X, o.Crop.Min.Y, o.Crop.Dx(), o.Crop.Dy()))
+	}
+	if o.Rotate != 0 {
+		query.Set("rotate", strconv.Itoa(o.Rotate))
+	}
+	if o.Format != "" {
+		query.Set("format", o.Format)
+	}
+	if o.Quality > 0 && o.Format == FormatJPEG {
+		query.Set("q", strconv.Itoa(o.Quality))
+	}
+	return query.Encode()
+}
+
+// OutputFormat retorna el formato de salida: el pedido o, si no se indicó, el
+// del original cuando es JPEG, PNG o WebP (PNG para el resto)
+func (o Options) OutputFormat(sourceFormat string) string {
+	if o.Format != "" {
+		return o.Format
+	}
+	switch sourceFormat {
+	case FormatJPEG, FormatPNG, FormatWebP:
+		return sourceFormat
+	}
+	return FormatPNG
+}
+
+// Decode lee una imagen validando sus dimensiones antes de decodificarla.
+// Retorna también el formato detectado (jpeg, png, gif o webp).
+func Decode(path string) (image.Image, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("imagen no soportada: %v", err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", fmt.Errorf("imagen demasiado grande: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("imagen no soportada: %v", err)
+	}
+	return img, format, nil
+}
+
+// Fit escala la imagen para que su lado mayor sea maxSide, sin agrandarla
+func Fit(src image.Image, maxSide int) *image.RGBA {
+	return Transform(src, Options{Width: maxSide, Height: maxSide})
+}
+
+// Transform aplica recorte, rotación y redimensión. En modo contain la imagen
+// nunca se agranda; cover y fill producen exactamente el tamaño pedido.
+func Transform(src image.Image, opts Options) *image.RGBA {
+	img := src
+	if !opts.Crop.Empty() {
+		rect := opts.Crop.Add(src.Bounds().Min).Intersect(src.Bounds())
+		if !rect.Empty() {
+			img = subImage(src, rect)
+		}
+	}
+	if opts.Rotate != 0 {
+		img = rotate(img, opts.Rotate)
+	}
+
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	width, height := opts.Width, opts.Height
+	srcRect := bounds
+
+	switch {
+	case width == 0 && height == 0:
+		width, height = srcW, srcH
+	case width == 0:
+		width = max(1, srcW*height/srcH)
+	case height == 0:
+		height = max(1, srcH*width/srcW)
+	}
+
+	switch opts.Fit {
+	case FitFill:
+	case FitCover:
+		// Recortar al centro la parte del original con la proporción pedida
+		if srcW*height > srcH*width {
+			cropW := max(1, srcH*width/height)
+			x := bounds.Min.X + (srcW-cropW)/2
+			srcRect = image.Rect(x, bounds.Min.Y, x+cropW, bounds.Max.Y)
+		} else {
+			cropH := max(1, srcW*height/width)
+			y := bounds.Min.Y + (srcH-cropH)/2
+			srcRect = image.Rect(bounds.Min.X, y, bounds.Max.X, y+cropH)
+		}
+	default:
+		if srcW <= width && srcH <= height {
+			width, height = srcW, srcH
+		} else if srcW*height > srcH*width {
+			height = max(1, srcH*width/srcW)
+		} else {
+			width = max(1, srcW*height/srcH)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, srcRect, draw.Src, nil)
+	return dst
+}
+
+// subImage recorta la imagen sin copiar píxeles si el tipo lo permite
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// rotate gira la imagen en sentido horario
+func rotate(src image.Image, degrees int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if degrees == 90 || degrees == 270 {
+		dstW, dstH = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := src.At(bounds.Min.X+x, bounds.Min.Y+y)
+			switch degrees {
+			case 90:
+				dst.Set(h-1-y, x, c)
+			case 180:
+				dst.Set(w-1-x, h-1-y, c)
+			case 270:
+				dst.Set(y, w-1-x, c)
+			default:
+				dst.Set(x, y, c)
+			}
+		}
+	}
+	return dst
+}
+
+// Encode escribe la imagen en el formato pedido. JPEG no tiene transparencias,
+// así que se compone sobre fondo blanco. WebP se genera sin pérdida (quality no aplica).
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case FormatJPEG:
+		if quality == 0 {
+			quality = DefaultQuality
+		}
+		return jpeg.Encode(w, flatten(img), &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatWebP:
+		return EncodeWebP(w, img)
+	}
+	return fmt.Errorf("formato no soportado: %s", format)
+}
+
+// flatten compone una imagen con transparencias sobre fondo blanco
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+	return dst
+}
+
+// ContentType retorna el tipo MIME y la extensión de un formato de salida
+func ContentType(format string) (string, string) {
+	switch format {
+	case FormatJPEG:
+		return "image/jpeg", ".jpg"
+	case FormatWebP:
+		return "image/webp", ".webp"
+	}
+	return "image/png", ".png"
+}
@@ -0,0 +1,404 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+)
+
+// Encoder WebP sin pérdida (VP8L) en Go puro. Usa las transformaciones
+// subtract-green y predictor (modo Select en bloques de 16x16) y codifica los
+// residuos con códigos de Huffman, sin referencias LZ77 ni color cache. No
+// alcanza la compresión de libwebp pero no requiere cgo.
+
+const (
+	vp8lSignature     = 0x2f
+	vp8lMaxSide       = 1 << 14
+	predictorBits     = 4 // bloques de 16x16
+	predictorSelect   = 11
+	maxCodeLength     = 15
+	maxCodeLengthCode = 7
+)
+
+// codeLengthCodeOrder es el orden en que se escriben las longitudes del código de longitudes
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// bitWriter escribe bits empezando por el menos significativo de cada byte
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+func (b *bitWriter) write(value uint32, n uint) {
+	b.acc |= uint64(value) << b.nBits
+	b.nBits += n
+	for b.nBits >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nBits -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.nBits > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nBits = 0, 0
+	}
+	return b.buf
+}
+
+// EncodeWebP escribe img como WebP sin pérdida
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSide || height > vp8lMaxSide {
+		return fmt.Errorf("dimensiones no soportadas por WebP: %dx%d", width, height)
+	}
+
+	// VP8L trabaja con ARGB no premultiplicado
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	pix := nrgba.Pix
+
+	hasAlpha := false
+	for i := 3; i < len(pix); i += 4 {
+		if pix[i] != 0xff {
+			hasAlpha = true
+			break
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(vp8lSignature, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if hasAlpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // versión
+
+	// Transformación subtract-green
+	bw.write(1, 1)
+	bw.write(2, 2)
+	residuals := make([]byte, len(pix))
+	copy(residuals, pix)
+	for i := 0; i < len(residuals); i += 4 {
+		residuals[i] -= residuals[i+1]
+		residuals[i+2] -= residuals[i+1]
+	}
+
+	// Transformación predictor: mismo modo en todos los bloques
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(predictorBits-2, 3)
+	tilesW := (width + 1<<predictorBits - 1) >> predictorBits
+	tilesH := (height + 1<<predictorBits - 1) >> predictorBits
+	tiles := make([]byte, 4*tilesW*tilesH)
+	for i := 0; i < len(tiles); i += 4 {
+		tiles[i+1] = predictorSelect
+		tiles[i+3] = 0xff
+	}
+	writeImageData(bw, tiles, false)
+	residuals = predict(residuals, width, height)
+
+	// Sin más transformaciones
+	bw.write(0, 1)
+	writeImageData(bw, residuals, true)
+
+	data := bw.bytes()
+	chunkSize := len(data)
+	padding := chunkSize & 1
+
+	header := make([]byte, 20)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(4+8+chunkSize+padding))
+	copy(header[8:12], "WEBP")
+	copy(header[12:16], "VP8L")
+	binary.LittleEndian.PutUint32(header[16:20], uint32(chunkSize))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// predict reemplaza cada pixel por su residuo respecto de la predicción,
+// con las reglas de VP8L para la primera fila y la primera columna
+func predict(pix []byte, width, height int) []byte {
+	out := make([]byte, len(pix))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := 4 * (y*width + x)
+			var pred [4]byte
+			switch {
+			case x == 0 && y == 0:
+				pred = [4]byte{0, 0, 0, 0xff}
+			case y == 0:
+				copy(pred[:], pix[p-4:p])
+			case x == 0:
+				copy(pred[:], pix[p-4*width:p-4*width+4])
+			default:
+				pred = selectPredictor(pix[p-4:p], pix[p-4*width:p-4*width+4], pix[p-4*width-4:p-4*width])
+			}
+			for c := 0; c < 4; c++ {
+				out[p+c] = pix[p+c] - pred[c]
+			}
+		}
+	}
+	return out
+}
+
+// selectPredictor elige el pixel izquierdo o el superior, el más parecido al gradiente
+func selectPredictor(left, top, topLeft []byte) [4]byte {
+	var distLeft, distTop int
+	for c := 0; c < 4; c++ {
+		distLeft += abs(int(topLeft[c]) - int(top[c]))
+		distTop += abs(int(topLeft[c]) - int(left[c]))
+	}
+	var pred [4]byte
+	if distLeft < distTop {
+		copy(pred[:], left)
+	} else {
+		copy(pred[:], top)
+	}
+	return pred
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// writeImageData codifica pixels RGBA como literales con un grupo de códigos de Huffman
+func writeImageData(bw *bitWriter, pix []byte, topLevel bool) {
+	bw.write(0, 1) // sin color cache
+	if topLevel {
+		bw.write(0, 1) // sin meta códigos de Huffman
+	}
+
+	// Histogramas por canal en el orden de VP8L: verde, rojo, azul, alfa, distancia
+	var counts [5][]int
+	counts[0] = make([]int, 256+24)
+	for i := 1; i < 4; i++ {
+		counts[i] = make([]int, 256)
+	}
+	counts[4] = make([]int, 40)
+	for i := 0; i < len(pix); i += 4 {
+		counts[0][pix[i+1]]++
+		counts[1][pix[i]]++
+		counts[2][pix[i+2]]++
+		counts[3][pix[i+3]]++
+	}
+
+	var codes [5]huffmanCode
+	for i := range counts {
+		codes[i] = writeHuffmanCode(bw, counts[i])
+	}
+
+	for i := 0; i < len(pix); i += 4 {
+		codes[0].write(bw, int(pix[i+1]))
+		codes[1].write(bw, int(pix[i]))
+		codes[2].write(bw, int(pix[i+2]))
+		codes[3].write(bw, int(pix[i+3]))
+	}
+}
+
+// huffmanCode son los códigos (ya invertidos para escribirse LSB primero) y sus longitudes
+type huffmanCode struct {
+	codes   []uint32
+	lengths []int
+}
+
+// newHuffmanCode arma el código a partir de las longitudes. Con un único símbolo
+// el decoder no lee bits, así que tampoco se escriben.
+func newHuffmanCode(lengths []int) huffmanCode {
+	used := 0
+	for _, n := range lengths {
+		if n > 0 {
+			used++
+		}
+	}
+	if used == 1 {
+		return huffmanCode{codes: make([]uint32, len(lengths)), lengths: make([]int, len(lengths))}
+	}
+	return huffmanCode{codes: canonicalCodes(lengths), lengths: lengths}
+}
+
+func (h huffmanCode) write(bw *bitWriter, symbol int) {
+	if n := h.lengths[symbol]; n > 0 {
+		bw.write(h.codes[symbol], uint(n))
+	}
+}
+
+// writeHuffmanCode escribe el código de un alfabeto según su histograma y lo retorna
+func writeHuffmanCode(bw *bitWriter, counts []int) huffmanCode {
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	// Código simple: uno o dos símbolos menores a 256
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		lengths := make([]int, len(counts))
+		bw.write(1, 1)
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		bw.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(used[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.write(uint32(used[1]), 8)
+			lengths[used[0]], lengths[used[1]] = 1, 1
+		}
+		return newHuffmanCode(lengths)
+	}
+
+	lengths := huffmanLengths(counts, maxCodeLength)
+
+	// Código de las longitudes (símbolos 0-15, sin repeticiones)
+	lengthCounts := make([]int, 19)
+	for _, n := range lengths {
+		lengthCounts[n]++
+	}
+	lengthLengths := huffmanLengths(lengthCounts, maxCodeLengthCode)
+	numCodes := 19
+	for numCodes > 4 && lengthLengths[codeLengthCodeOrder[numCodes-1]] == 0 {
+		numCodes--
+	}
+
+	bw.write(0, 1)
+	bw.write(uint32(numCodes-4), 4)
+	for i := 0; i < numCodes; i++ {
+		bw.write(uint32(lengthLengths[codeLengthCodeOrder[i]]), 3)
+	}
+	bw.write(0, 1) // max_symbol = tamaño del alfabeto
+
+	lengthCode := newHuffmanCode(lengthLengths)
+	for _, n := range lengths {
+		lengthCode.write(bw, n)
+	}
+	return newHuffmanCode(lengths)
+}
+
+// huffmanLengths calcula longitudes de código de Huffman limitadas a maxLength
+// bits. Si el árbol queda muy profundo se aplanan los conteos y se reintenta.
+func huffmanLengths(counts []int, maxLength int) []int {
+	freqs := make([]int, len(counts))
+	copy(freqs, counts)
+
+	for {
+		lengths := buildHuffmanLengths(freqs)
+		longest := 0
+		for _, n := range lengths {
+			if n > longest {
+				longest = n
+			}
+		}
+		if longest <= maxLength {
+			return lengths
+		}
+		for i, f := range freqs {
+			if f > 0 {
+				freqs[i] = (f + 1) / 2
+			}
+		}
+	}
+}
+
+// buildHuffmanLengths arma el árbol de Huffman y retorna la profundidad de cada símbolo
+func buildHuffmanLengths(freqs []int) []int {
+	type node struct {
+		freq        int
+		symbol      int
+		left, right int
+	}
+	var nodes []node
+	var queue []int
+	for symbol, f := range freqs {
+		if f > 0 {
+			nodes = append(nodes, node{freq: f, symbol: symbol, left: -1, right: -1})
+			queue = append(queue, len(nodes)-1)
+		}
+	}
+
+	lengths := make([]int, len(freqs))
+	if len(queue) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+
+	for len(queue) > 1 {
+		sort.SliceStable(queue, func(i, j int) bool {
+			return nodes[queue[i]].freq < nodes[queue[j]].freq
+		})
+		a, b := queue[0], queue[1]
+		nodes = append(nodes, node{freq: nodes[a].freq + nodes[b].freq, symbol: -1, left: a, right: b})
+		queue = append(queue[2:], len(nodes)-1)
+	}
+
+	var walk func(n, depth int)
+	walk = func(n, depth int) {
+		if nodes[n].left < 0 {
+			lengths[nodes[n].symbol] = depth
+			return
+		}
+		walk(nodes[n].left, depth+1)
+		walk(nodes[n].right, depth+1)
+	}
+	walk(queue[0], 0)
+	return lengths
+}
+
+// canonicalCodes asigna los códigos canónicos y los invierte para escribirlos LSB primero
+func canonicalCodes(lengths []int) []uint32 {
+	var count [maxCodeLength + 1]uint32
+	for _, n := range lengths {
+		if n > 0 {
+			count[n]++
+		}
+	}
+	var next [maxCodeLength + 2]uint32
+	code := uint32(0)
+	for n := 1; n <= maxCodeLength; n++ {
+		code = (code + count[n-1]) << 1
+		next[n] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		c := next[n]
+		next[n]++
+		var reversed uint32
+		for i := 0; i < n; i++ {
+			reversed = reversed<<1 | (c>>i)&1
+		}
+		codes[symbol] = reversed
+	}
+	return codes
+}
@@ -0,0 +1,90 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// fill arma una imagen RGBA de w x h con el color que retorna pixel
+func fill(w, h int, pixel func(x, y int) color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, pixel(x, y))
+		}
+	}
+	return img
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"un pixel", fill(1, 1, func(x, y int) color.RGBA { return color.RGBA{10, 20, 30, 0xff} })},
+		{"opaca", fill(7, 5, func(x, y int) color.RGBA {
+			return color.RGBA{uint8(x * 36), uint8(y * 60), uint8(x*y*11 + 3), 0xff}
+		})},
+		{"transparente", fill(9, 6, func(x, y int) color.RGBA {
+			a := uint8(x * 31)
+			return color.RGBA{a / 2, a / 3, a, a}
+		})},
+		{"totalmente transparente", image.NewRGBA(image.Rect(0, 0, 4, 4))},
+		{"un solo color", fill(16, 16, func(x, y int) color.RGBA { return color.RGBA{0x33, 0x66, 0x99, 0xff} })},
+		{"tamaño impar", fill(33, 17, func(x, y int) color.RGBA {
+			return color.RGBA{uint8(x ^ y), uint8(x * 7), uint8(y * 13), 0xff}
+		})},
+		{"columna", fill(1, 23, func(x, y int) color.RGBA { return color.RGBA{uint8(y * 11), 0, 0xff, 0xff} })},
+		{"ruido", fill(37, 29, func(x, y int) color.RGBA {
+			a := uint8(random.Intn(256))
+			return color.RGBA{uint8(random.Intn(int(a) + 1)), uint8(random.Intn(int(a) + 1)), uint8(random.Intn(int(a) + 1)), a}
+		})},
+		{"bounds con origen distinto de cero", fill(12, 10, func(x, y int) color.RGBA {
+			return color.RGBA{uint8(x * 20), uint8(y * 25), 0x80, 0xff}
+		}).SubImage(image.Rect(3, 2, 10, 9))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, tt.img); err != nil {
+				t.Fatalf("EncodeWebP(): %v", err)
+			}
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("webp.Decode(): %v", err)
+			}
+
+			bounds := tt.img.Bounds()
+			if decoded.Bounds().Dx() != bounds.Dx() || decoded.Bounds().Dy() != bounds.Dy() {
+				t.Fatalf("dimensiones = %v, esperado %v", decoded.Bounds().Size(), bounds.Size())
+			}
+			for y := 0; y < bounds.Dy(); y++ {
+				for x := 0; x < bounds.Dx(); x++ {
+					want := color.NRGBAModel.Convert(tt.img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+					got := color.NRGBAModel.Convert(decoded.At(decoded.Bounds().Min.X+x, decoded.Bounds().Min.Y+y)).(color.NRGBA)
+					if got != want {
+						t.Fatalf("pixel (%d,%d) = %v, esperado %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPInvalidSize(t *testing.T) {
+	for _, rect := range []image.Rectangle{
+		image.Rect(0, 0, 0, 5),
+		image.Rect(0, 0, vp8lMaxSide+1, 1),
+	} {
+		if err := EncodeWebP(&bytes.Buffer{}, image.NewRGBA(rect)); err == nil {
+			t.Errorf("EncodeWebP(%v) no retornó error", rect)
+		}
+	}
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"file-server-sofmar/config"
 	"file-server-sofmar/handlers"
 	"file-server-sofmar/middleware"
+	"file-server-sofmar/scanner"
+	"file-server-sofmar/storage"
+	"file-server-sofmar/tlsconfig"
+	"file-server-sofmar/tracing"
 
 	gorrillaHandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -16,30 +24,106 @@ import (
 func main() {
 	// Cargar configuración
 	cfg := config.Load()
+	middleware.SetupLogger(cfg.LogFormat, cfg.LogLevel)
+
+	// Trazas OpenTelemetry
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracesExporter, cfg.ServiceName)
+	if err != nil {
+		slog.Error("error configurando trazas", "error", err)
+		os.Exit(1)
+	}
+
+	// Construir índice de archivos (fileId -> ubicación)
+	indexed, err := storage.RebuildIndex()
+	if err != nil {
+		slog.Error("error construyendo índice de archivos", "error", err)
+	}
+	slog.Info("índice de archivos construido", "files", indexed)
+	stopWatcher := make(chan struct{})
+	go storage.WatchIndex(cfg.IndexRefreshInterval, stopWatcher)
+
+	// Antivirus para los clientes con MalwareScan. Sin CLAMD_ADDRESS un cliente
+	// sin FailOpen rechazaría todas las subidas, así que no se arranca; uno con
+	// FailOpen las acepta como pendientes hasta que se configure.
+	for clientID, clientConfig := range config.ClientConfigs {
+		if policy := clientConfig.MalwareScan; policy != nil && cfg.ClamdAddress == "" {
+			if !policy.FailOpen {
+				slog.Error("el cliente requiere análisis antivirus pero CLAMD_ADDRESS no está configurado", "client", clientID)
+				os.Exit(1)
+			}
+			slog.Warn("CLAMD_ADDRESS no está configurado: las subidas del cliente quedan pendientes de análisis", "client", clientID)
+		}
+		// Subidas que quedaron a medio analizar en una ejecución anterior
+		if err := storage.RemoveStaging(clientConfig.StoragePath); err != nil {
+			slog.Warn("error limpiando staging", "client", clientID, "error", err)
+		}
+	}
+	if cfg.ClamdAddress != "" {
+		scanner.SetDefault(scanner.NewClamd(cfg.ClamdAddress, cfg.ClamdTimeout))
+	}
+	go handlers.RetryPendingScans(cfg.ScanRetryInterval, stopWatcher)
+
+	// Cargar índice de contenido (búsqueda de texto) en segundo plano
+	go handlers.LoadContentIndex()
 
 	// Crear router principal
 	r := mux.NewRouter()
 
 	// Middleware global
+	r.Use(middleware.BodyIdleTimeout(cfg.BodyIdleTimeout))
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Tracing())
 	r.Use(middleware.CORS())
 	r.Use(middleware.Logging())
+	r.Use(middleware.Metrics())
 
 	// API Routes
 	api := r.PathPrefix("/api").Subrouter()
 
 	// Auth endpoint (sin autenticación)
-	api.HandleFunc("/login", handlers.Login).Methods("POST")
+	login := middleware.Traced("middleware.RateLimit", middleware.RateLimit())(
+		middleware.HandlerSpan()(http.HandlerFunc(handlers.Login)),
+	)
+	api.Handle("/login", login).Methods("POST")
+
+	// Admin endpoints (JWT del administrador)
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.Traced("middleware.AdminAuth", middleware.AdminAuth()))
+	admin.Use(middleware.HandlerSpan())
+	admin.HandleFunc("/lockouts", handlers.ListLockouts).Methods("GET")
+	admin.HandleFunc("/unlock", handlers.UnlockLogin).Methods("POST")
 
 	// File endpoints (con autenticación)
 	files := api.PathPrefix("/files").Subrouter()
-	files.Use(middleware.ClientValidation())
-	files.Use(middleware.JWTAuth()) // 🔐 Autenticación JWT
+	files.Use(middleware.Traced("middleware.ClientValidation", middleware.ClientValidation()))
+	files.Use(middleware.Traced("middleware.IPRateLimit", middleware.IPRateLimit()))
+	files.Use(middleware.Traced("middleware.JWTAuth", middleware.JWTAuth())) // 🔐 Autenticación JWT
+	files.Use(middleware.Traced("middleware.RateLimit", middleware.RateLimit()))
+	files.Use(middleware.HandlerSpan())
 	files.HandleFunc("/upload", handlers.UploadFile).Methods("POST")
-	files.HandleFunc("/download/{fileId}", handlers.DownloadFile).Methods("GET")
+	files.HandleFunc("/download/{fileId}", handlers.DownloadFile).Methods("GET", "HEAD")
 	files.HandleFunc("/list/{client}", handlers.ListFiles).Methods("GET")
 	files.HandleFunc("/{fileId}", handlers.DeleteFile).Methods("DELETE")
 	files.HandleFunc("/metadata/{fileId}", handlers.GetMetadata).Methods("GET")
+	files.HandleFunc("/metadata/{fileId}", handlers.UpdateMetadata).Methods("PATCH")
 	files.HandleFunc("/search/{client}", handlers.SearchFiles).Methods("POST")
+	files.HandleFunc("/search/{client}", handlers.SearchFilesQuery).Methods("GET")
+	files.HandleFunc("/copy/{fileId}", handlers.CopyFile).Methods("POST")
+	files.HandleFunc("/archive", handlers.ArchiveFiles).Methods("POST")
+	files.HandleFunc("/thumbnail/{fileId}", handlers.GetThumbnail).Methods("GET", "HEAD")
+	files.HandleFunc("/transform/{fileId}", handlers.TransformImage).Methods("GET", "HEAD")
+	files.HandleFunc("/preview/{fileId}", handlers.PreviewFile).Methods("GET")
+	files.HandleFunc("/signed-url/{fileId}", handlers.CreateSignedURL).Methods("GET")
+
+	// URLs estáticas de los archivos (/static/{client}/{carpeta}/{archivo})
+	static := r.PathPrefix("/static/").Subrouter()
+	static.Use(middleware.Traced("middleware.StaticAuth", middleware.StaticAuth()))
+	static.Use(middleware.Traced("middleware.RateLimit", middleware.RateLimit()))
+	static.Use(middleware.HandlerSpan())
+	static.PathPrefix("/").HandlerFunc(handlers.ServeStatic).Methods("GET", "HEAD")
+
+	// Métricas Prometheus
+	r.Handle("/metrics", middleware.MetricsHandler()).Methods("GET")
 
 	// Health check
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -51,8 +135,8 @@ func main() {
 	// Configurar CORS
 	corsHandler := gorrillaHandlers.CORS(
 		gorrillaHandlers.AllowedOrigins(cfg.AllowedOrigins),
-		gorrillaHandlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		gorrillaHandlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Client-Id"}),
+		gorrillaHandlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		gorrillaHandlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Client-Id", "X-Request-Id", "traceparent", "tracestate"}),
 	)(r)
 
 	port := cfg.Port
@@ -60,10 +144,122 @@ func main() {
 		port = "3000"
 	}
 
-	fmt.Printf("🚀 Servidor de archivos iniciado en puerto %s\n", port)
-	fmt.Printf("📁 Directorio de uploads: %s\n", cfg.UploadDir)
-	fmt.Printf("🌐 Health check: http://localhost:%s/health\n", port)
-	fmt.Printf("📊 API endpoints: http://localhost:%s/api/files/\n", port)
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           corsHandler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    1 << 20,
+	}
+
+	// TLS nativo con recarga de certificados y HTTP/2
+	scheme := "http"
+	if cfg.TLSCertFile != "" {
+		// La CA global y las de cada cliente con mTLS se aceptan en el handshake;
+		// qué certificado corresponde a qué cliente se decide en JWTAuth
+		clientCAFiles := config.ClientCertCAFiles()
+		if len(clientCAFiles) > 0 && cfg.TLSClientAuth == tlsconfig.ClientAuthNone {
+			cfg.TLSClientAuth = tlsconfig.ClientAuthOptional
+		}
+		if cfg.TLSClientCAFile != "" {
+			clientCAFiles = append(clientCAFiles, cfg.TLSClientCAFile)
+		}
+
+		tlsConfig, reloader, err := tlsconfig.New(cfg.TLSCertFile, cfg.TLSKeyFile, clientCAFiles, cfg.TLSClientAuth)
+		if err != nil {
+			slog.Error("error configurando TLS", "error", err)
+			os.Exit(1)
+		}
+		server.TLSConfig = tlsConfig
+		go reloader.Watch(cfg.TLSReloadInterval, stopWatcher)
+		scheme = "https"
+	}
+
+	slog.Info("servidor de archivos iniciado",
+		"port", port,
+		"upload_dir", cfg.UploadDir,
+		"tls", scheme == "https",
+		"mtls", cfg.TLSClientAuth,
+		"health", scheme+"://localhost:"+port+"/health",
+		"api", scheme+"://localhost:"+port+"/api/files/",
+	)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	// Redirección HTTP -> HTTPS
+	var redirectServer *http.Server
+	if scheme == "https" && cfg.HTTPRedirectPort != "" {
+		redirectServer = &http.Server{
+			Addr:              ":" + cfg.HTTPRedirectPort,
+			Handler:           httpsRedirect(port),
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+		go func() {
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverErr <- err
+			}
+		}()
+		slog.Info("redirección HTTP a HTTPS", "port", cfg.HTTPRedirectPort)
+	}
+
+	// Esperar SIGTERM (docker stop / deploy) o SIGINT
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		slog.Error("servidor detenido", "error", err)
+		shutdownTracing(context.Background())
+		os.Exit(1)
+	case sig := <-signals:
+		slog.Info("apagando servidor", "signal", sig.String(), "timeout", cfg.ShutdownTimeout.String())
+	}
+
+	// Dejar de aceptar conexiones y esperar a las transferencias activas
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if redirectServer != nil {
+		redirectServer.Close()
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("transferencias activas sin terminar, cerrando conexiones", "error", err)
+		server.Close()
+	}
+
+	// Borrar archivos que quedaron a medio escribir
+	if removed := storage.RemovePartials(); removed > 0 {
+		slog.Warn("archivos parciales eliminados", "count", removed)
+	}
+
+	close(stopWatcher)
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("error cerrando exportador de trazas", "error", err)
+	}
+	slog.Info("servidor apagado")
+}
 
-	log.Fatal(http.ListenAndServe(":"+port, corsHandler))
-}
\ No newline at end of file
+// httpsRedirect redirige permanentemente cualquier request HTTP a HTTPS
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
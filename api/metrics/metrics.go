@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/storage"
+)
+
+const namespace = "fileserver"
+
+var (
+	// RequestsTotal cuenta las requests HTTP por ruta, método, status y cliente
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Requests HTTP procesadas.",
+	}, []string{"route", "method", "status", "client"})
+
+	// RequestDuration mide la latencia de las requests HTTP
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duración de las requests HTTP.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"route", "method", "status", "client"})
+
+	// UploadedBytes cuenta los bytes recibidos en subidas por cliente
+	UploadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploaded_bytes_total",
+		Help:      "Bytes recibidos en subidas de archivos.",
+	}, []string{"client"})
+
+	// DownloadedBytes cuenta los bytes enviados en descargas por cliente
+	DownloadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes enviados en descargas de archivos.",
+	}, []string{"client"})
+
+	// ActiveUploads es la cantidad de subidas en curso
+	ActiveUploads = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_uploads",
+		Help:      "Subidas de archivos en curso.",
+	})
+
+	// AuthFailures cuenta los fallos de autenticación por motivo
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Fallos de autenticación (login fallido, bloqueado, token faltante o inválido).",
+	}, []string{"reason"})
+
+	// RateLimitRejections cuenta las requests rechazadas por rate limiting
+	RateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rechazadas con 429 por rate limiting.",
+	}, []string{"limit", "client"})
+
+	// MalwareScans cuenta los análisis antivirus por resultado (clean, infected, error)
+	MalwareScans = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "malware_scans_total",
+		Help:      "Archivos analizados con el antivirus.",
+	}, []string{"client", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(storageCollector{})
+}
+
+var (
+	storageBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "storage", "bytes"),
+		"Espacio ocupado por los archivos de cada cliente.",
+		[]string{"client"}, nil,
+	)
+	storageFilesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "storage", "files"),
+		"Cantidad de archivos de cada cliente.",
+		[]string{"client"}, nil,
+	)
+)
+
+// storageCollector calcula el uso de almacenamiento por cliente en cada scrape
+// a partir del índice en memoria (sin recorrer el disco)
+type storageCollector struct{}
+
+func (storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- storageBytesDesc
+	ch <- storageFilesDesc
+}
+
+func (storageCollector) Collect(ch chan<- prometheus.Metric) {
+	for clientID := range config.ClientConfigs {
+		files, err := storage.ClientFiles(context.Background(), clientID)
+		if err != nil {
+			continue
+		}
+
+		var total int64
+		for _, file := range files {
+			total += file.Size
+		}
+		ch <- prometheus.MustNewConstMetric(storageBytesDesc, prometheus.GaugeValue, float64(total), clientID)
+		ch <- prometheus.MustNewConstMetric(storageFilesDesc, prometheus.GaugeValue, float64(len(files)), clientID)
+	}
+}
@@ -3,16 +3,20 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"file-server-sofmar/config"
+	"file-server-sofmar/metrics"
 	"file-server-sofmar/models"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWTAuth middleware para autenticación JWT opcional
+// JWTAuth middleware para autenticación JWT opcional. Los clientes con
+// ClientCert configurado también aceptan un certificado de cliente verificado
+// en lugar del bearer token.
 func JWTAuth() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -27,22 +31,41 @@ func JWTAuth() func(http.Handler) http.Handler {
 				return
 			}
 
+			// Certificado de cliente (mTLS) como alternativa al token
+			var certErr error
+			if clientConfig.ClientCert != nil {
+				identity, err := verifyClientCertificate(r, clientConfig.ClientCert)
+				if identity != "" {
+					ctx := context.WithValue(r.Context(), "userID", identity)
+					ctx = context.WithValue(ctx, "serviceIdentity", identity)
+					setRequestUser(ctx, identity)
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+				certErr = err
+			}
+
 			// Cliente requiere autenticación, verificar token
 			token := extractToken(r)
 			if token == "" {
-				unauthorizedResponse(w, "Token de autenticación requerido")
+				if certErr != nil {
+					unauthorizedResponse(w, "cert_invalid", "Certificado de cliente inválido: "+certErr.Error())
+					return
+				}
+				unauthorizedResponse(w, "token_missing", "Token de autenticación requerido")
 				return
 			}
 
 			// Validar JWT token
 			userID, err := validateJWTToken(token)
 			if err != nil {
-				unauthorizedResponse(w, "Token inválido: "+err.Error())
+				unauthorizedResponse(w, "token_invalid", "Token inválido: "+err.Error())
 				return
 			}
 
 			// Añadir user ID al contexto
 			ctx := context.WithValue(r.Context(), "userID", userID)
+			setRequestUser(ctx, userID)
 			r = r.WithContext(ctx)
 
 			next.ServeHTTP(w, r)
@@ -50,6 +73,71 @@ func JWTAuth() func(http.Handler) http.Handler {
 	}
 }
 
+// AdminAuth middleware que exige un JWT del usuario administrador
+func AdminAuth() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := extractToken(r)
+			if token == "" {
+				unauthorizedResponse(w, "token_missing", "Token de autenticación requerido")
+				return
+			}
+
+			userID, err := validateJWTToken(token)
+			if err != nil {
+				unauthorizedResponse(w, "token_invalid", "Token inválido: "+err.Error())
+				return
+			}
+
+			if userID != config.Load().AdminUser {
+				metrics.AuthFailures.WithLabelValues("forbidden").Inc()
+				errorResponse := models.ErrorResponse{
+					Success:   false,
+					Error:     "Se requieren permisos de administrador",
+					Code:      http.StatusForbidden,
+					RequestID: w.Header().Get(RequestIDHeader),
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(errorResponse)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "userID", userID)
+			setRequestUser(ctx, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AuthorizeClient verifica que la request tenga acceso a un cliente distinto
+// al del contexto (por ejemplo, el destino de una copia entre clientes)
+func AuthorizeClient(r *http.Request, clientID string) error {
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		return fmt.Errorf("cliente no configurado: %s", clientID)
+	}
+	if !clientConfig.RequiresAuth {
+		return nil
+	}
+
+	if clientConfig.ClientCert != nil {
+		if identity, _ := verifyClientCertificate(r, clientConfig.ClientCert); identity != "" {
+			return nil
+		}
+	}
+
+	token := extractToken(r)
+	if token == "" {
+		return fmt.Errorf("token de autenticación requerido para %s", clientID)
+	}
+
+	if _, err := validateJWTToken(token); err != nil {
+		return fmt.Errorf("token inválido: %v", err)
+	}
+	return nil
+}
+
 // extractToken extrae el token JWT del header Authorization
 func extractToken(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
@@ -90,18 +178,24 @@ func validateJWTToken(tokenString string) (string, error) {
 		if userID, exists := claims["user_id"]; exists {
 			return userID.(string), nil
 		}
+		if userID, ok := claims["user"].(string); ok {
+			return userID, nil
+		}
 		return "unknown", nil
 	}
 
 	return "", jwt.ErrSignatureInvalid
 }
 
-// unauthorizedResponse envía una respuesta 401
-func unauthorizedResponse(w http.ResponseWriter, message string) {
+// unauthorizedResponse envía una respuesta 401 y registra el fallo en métricas
+func unauthorizedResponse(w http.ResponseWriter, reason, message string) {
+	metrics.AuthFailures.WithLabelValues(reason).Inc()
+
 	errorResponse := models.ErrorResponse{
-		Success: false,
-		Error:   message,
-		Code:    http.StatusUnauthorized,
+		Success:   false,
+		Error:     message,
+		Code:      http.StatusUnauthorized,
+		RequestID: w.Header().Get(RequestIDHeader),
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -26,9 +26,10 @@ func ClientValidation() func(http.Handler) http.Handler {
 			// Validar que el cliente existe
 			if !config.IsValidClient(clientID) {
 				errorResponse := models.ErrorResponse{
-					Success: false,
-					Error:   "Cliente no válido: " + clientID,
-					Code:    http.StatusBadRequest,
+					Success:   false,
+					Error:     "Cliente no válido: " + clientID,
+					Code:      http.StatusBadRequest,
+					RequestID: w.Header().Get(RequestIDHeader),
 				}
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusBadRequest)
@@ -38,6 +39,7 @@ func ClientValidation() func(http.Handler) http.Handler {
 
 			// Añadir client ID al contexto
 			ctx := context.WithValue(r.Context(), "clientID", clientID)
+			setRequestClient(ctx, clientID)
 			r = r.WithContext(ctx)
 
 			next.ServeHTTP(w, r)
@@ -70,6 +72,11 @@ func extractClientID(r *http.Request) string {
 		return clientID
 	}
 
+	// 4. Cliente asociado al certificado TLS presentado (integraciones mTLS)
+	if clientID := clientFromCertificate(r); clientID != "" {
+		return clientID
+	}
+
 	return ""
 }
 
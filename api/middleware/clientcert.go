@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"file-server-sofmar/config"
+)
+
+// caPool es un bundle de CAs cacheado junto con el mtime del archivo
+type caPool struct {
+	modTime time.Time
+	pool    *x509.CertPool
+}
+
+var caPools = struct {
+	sync.Mutex
+	pools map[string]caPool
+}{pools: make(map[string]caPool)}
+
+// loadCAPool retorna el pool de un bundle de CAs, releyéndolo si el archivo cambió
+func loadCAPool(caFile string) (*x509.CertPool, error) {
+	info, err := os.Stat(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	caPools.Lock()
+	defer caPools.Unlock()
+
+	if cached, ok := caPools.pools[caFile]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.pool, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA sin certificados válidos: %s", caFile)
+	}
+	caPools.pools[caFile] = caPool{modTime: info.ModTime(), pool: pool}
+	return pool, nil
+}
+
+// verifyClientCertificate verifica el certificado presentado en la conexión TLS
+// contra la CA y los subjects permitidos de un cliente. Retorna la identidad de
+// servicio (CN del certificado) o "" si la request no trae certificado.
+func verifyClientCertificate(r *http.Request, certAuth *config.ClientCertAuth) (string, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", nil
+	}
+	if certAuth == nil || certAuth.CAFile == "" {
+		return "", fmt.Errorf("el cliente no admite autenticación con certificado")
+	}
+
+	roots, err := loadCAPool(certAuth.CAFile)
+	if err != nil {
+		return "", err
+	}
+
+	leaf := r.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return "", fmt.Errorf("certificado no emitido por la CA del cliente")
+	}
+
+	if !subjectAllowed(leaf, certAuth.AllowedSubjects) {
+		return "", fmt.Errorf("certificado no autorizado: %s", leaf.Subject.String())
+	}
+
+	if leaf.Subject.CommonName != "" {
+		return leaf.Subject.CommonName, nil
+	}
+	return leaf.Subject.String(), nil
+}
+
+// subjectAllowed verifica el CN o el DN completo del certificado
+func subjectAllowed(cert *x509.Certificate, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, subject := range allowed {
+		subject = strings.TrimSpace(subject)
+		if subject == cert.Subject.String() || strings.TrimPrefix(subject, "CN=") == cert.Subject.CommonName {
+			return true
+		}
+	}
+	return false
+}
+
+// clientFromCertificate busca el cliente cuyo certificado coincide con el
+// presentado en la conexión, para requests que no indican cliente
+func clientFromCertificate(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+
+	clientIDs := config.GetAllClients()
+	sort.Strings(clientIDs)
+	for _, clientID := range clientIDs {
+		clientConfig, _ := config.GetClientConfig(clientID)
+		if clientConfig.ClientCert == nil {
+			continue
+		}
+		if identity, err := verifyClientCertificate(r, clientConfig.ClientCert); err == nil && identity != "" {
+			return clientID
+		}
+	}
+	return ""
+}
+
+// GetServiceIdentityFromContext obtiene la identidad de servicio autenticada por
+// certificado de cliente ("" si la request se autenticó con JWT o no requiere auth)
+func GetServiceIdentityFromContext(ctx context.Context) string {
+	if identity, ok := ctx.Value("serviceIdentity").(string); ok {
+		return identity
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"file-server-sofmar/config"
+)
+
+var (
+	trustedProxiesOnce sync.Once
+	trustedProxies     []*net.IPNet
+)
+
+// loadTrustedProxies parsea TRUSTED_PROXIES (IPs sueltas o redes CIDR)
+func loadTrustedProxies() {
+	for _, entry := range config.Load().TrustedProxies {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			slog.Warn("proxy de confianza inválido", "entry", entry, "error", err)
+			continue
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+// isTrustedProxy indica si una IP pertenece a un proxy de confianza
+func isTrustedProxy(ip net.IP) bool {
+	trustedProxiesOnce.Do(loadTrustedProxies)
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP obtiene la IP real de quien hace la request. Solo si la conexión viene
+// de un proxy de confianza (nginx) se usa X-Forwarded-For, recorriéndolo de derecha
+// a izquierda hasta la primera IP que no sea de un proxy de confianza.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote := net.ParseIP(host)
+	if remote == nil || !isTrustedProxy(remote) {
+		return host
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if !isTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+
+	if realIP := net.ParseIP(r.Header.Get("X-Real-IP")); realIP != nil {
+		return realIP.String()
+	}
+	return host
+}
@@ -1,12 +1,33 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
-// Logging middleware para registrar todas las requests
+// SetupLogger configura el logger por defecto (slog) en formato "json" o "text".
+// Los log.Printf que queden en dependencias también pasan por este handler.
+func SetupLogger(format, level string) {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		logLevel = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "text") {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// Logging middleware para registrar todas las requests. Debe montarse después
+// de RequestID para incluir el ID, el cliente y el usuario en cada línea.
 func Logging() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -22,20 +43,20 @@ func Logging() func(http.Handler) http.Handler {
 			next.ServeHTTP(wrapper, r)
 
 			// Log de la request
-			duration := time.Since(start)
-			clientID := GetClientFromContext(r.Context())
-			userID := GetUserFromContext(r.Context())
+			level := slog.LevelInfo
+			if wrapper.statusCode >= http.StatusInternalServerError {
+				level = slog.LevelError
+			} else if wrapper.statusCode >= http.StatusBadRequest {
+				level = slog.LevelWarn
+			}
 
-			log.Printf(
-				"[%s] %s %s | Status: %d | Duration: %v | Client: %s | User: %s | Size: %d bytes",
-				r.Method,
-				r.URL.Path,
-				r.RemoteAddr,
-				wrapper.statusCode,
-				duration,
-				clientID,
-				userID,
-				wrapper.bytesWritten,
+			Logger(r.Context()).Log(r.Context(), level, "request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", wrapper.statusCode,
+				"duration_ms", float64(time.Since(start).Microseconds())/1000,
+				"bytes", wrapper.bytesWritten,
+				"user_agent", r.UserAgent(),
 			)
 		})
 	}
@@ -58,14 +79,7 @@ func (rw *responseWrapper) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
-// RateLimit middleware básico para prevenir abuso
-func RateLimit() func(http.Handler) http.Handler {
-	// Implementación básica - en producción usar redis o similar
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// TODO: Implementar rate limiting real con redis/memoria
-			// Por ahora solo pasamos la request
-			next.ServeHTTP(w, r)
-		})
-	}
-}
\ No newline at end of file
+// Unwrap permite a http.ResponseController llegar al ResponseWriter original
+func (rw *responseWrapper) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
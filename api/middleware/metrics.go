@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Rutas cuyo tráfico se contabiliza como subida o descarga de archivos
+const (
+	uploadRoute   = "/api/files/upload"
+	downloadRoute = "/api/files/download/{fileId}"
+	archiveRoute  = "/api/files/archive"
+	staticRoute   = "/static/"
+)
+
+// Metrics middleware que registra requests, latencias y bytes transferidos.
+// Usa el mismo responseWrapper que Logging y debe montarse después de RequestID
+// para conocer el cliente resuelto más adentro de la cadena.
+func Metrics() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// Usar la plantilla de la ruta para no generar una serie por fileId
+			route := routeTemplate(r)
+
+			var body *countingReader
+			if route == uploadRoute && r.Body != nil {
+				body = &countingReader{ReadCloser: r.Body}
+				r.Body = body
+				metrics.ActiveUploads.Inc()
+				defer metrics.ActiveUploads.Dec()
+			}
+
+			wrapper := &responseWrapper{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapper, r)
+
+			clientID := "none"
+			if info := getRequestInfo(r.Context()); info != nil {
+				info.mu.Lock()
+				if info.clientID != "" {
+					clientID = info.clientID
+				}
+				info.mu.Unlock()
+			}
+
+			status := strconv.Itoa(wrapper.statusCode)
+			metrics.RequestsTotal.WithLabelValues(route, r.Method, status, clientID).Inc()
+			metrics.RequestDuration.WithLabelValues(route, r.Method, status, clientID).Observe(time.Since(start).Seconds())
+
+			switch {
+			case body != nil:
+				metrics.UploadedBytes.WithLabelValues(clientID).Add(float64(body.n))
+			case route == downloadRoute || route == archiveRoute || route == staticRoute:
+				metrics.DownloadedBytes.WithLabelValues(clientID).Add(float64(wrapper.bytesWritten))
+			}
+		})
+	}
+}
+
+// MetricsHandler expone las métricas en formato Prometheus. Si METRICS_TOKEN
+// está configurado exige "Authorization: Bearer <token>".
+func MetricsHandler() http.Handler {
+	handler := promhttp.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := config.Load().MetricsToken; token != "" {
+			if subtle.ConstantTimeCompare([]byte(extractToken(r)), []byte(token)) != 1 {
+				unauthorizedResponse(w, "metrics_token", "Token de métricas inválido")
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// countingReader cuenta los bytes leídos del body de una request
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
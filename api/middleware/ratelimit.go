@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/metrics"
+	"file-server-sofmar/models"
+)
+
+// Categorías de rate limiting (cada una tiene su propio presupuesto)
+const (
+	limitLogin       = "login"
+	limitUpload      = "upload"
+	limitUploadBytes = "upload-bytes"
+	limitDownload    = "download"
+	limitAPI         = "api"
+	limitClient      = "client"
+	limitIP          = "ip"
+)
+
+// Limit define un token bucket: Rate tokens por segundo con capacidad Burst
+type Limit struct {
+	Rate  float64
+	Burst float64
+}
+
+// perMinute arma un límite de n requests por minuto con ráfaga de n
+func perMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: float64(n)}
+}
+
+// RateLimitResult es el resultado de consumir tokens de un bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  float64
+	RetryAfter time.Duration // cuánto esperar hasta tener los tokens pedidos
+	ResetAfter time.Duration // cuánto falta para que el bucket esté lleno
+}
+
+// RateLimitStore guarda el estado de los token buckets. La implementación en
+// memoria sirve para una instancia; para varias instancias detrás de un balanceador
+// se puede implementar sobre un store compartido (Redis, etc.) y usar SetRateLimitStore.
+type RateLimitStore interface {
+	Allow(key string, limit Limit, cost float64) RateLimitResult
+}
+
+var rateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+// SetRateLimitStore reemplaza el store usado por el middleware RateLimit
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitStore = store
+}
+
+// memoryBucket es el estado de un token bucket en memoria
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// MemoryRateLimitStore implementa RateLimitStore en memoria
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+// NewMemoryRateLimitStore crea un store en memoria que descarta periódicamente
+// los buckets que se rellenaron por completo
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+	go store.cleanup(time.Minute)
+	return store
+}
+
+// Allow consume cost tokens del bucket key si hay suficientes
+func (s *MemoryRateLimitStore) Allow(key string, limit Limit, cost float64) RateLimitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: limit.Burst, last: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+
+	// Rellenar según el tiempo transcurrido
+	b.tokens = math.Min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	result := RateLimitResult{}
+	if b.tokens >= cost {
+		b.tokens -= cost
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((cost - b.tokens) / limit.Rate)
+	}
+	result.Remaining = b.tokens
+	result.ResetAfter = secondsToDuration((limit.Burst - b.tokens) / limit.Rate)
+	return result
+}
+
+// cleanup elimina los buckets llenos, que equivalen a no tener estado
+func (s *MemoryRateLimitStore) cleanup(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.mu.Lock()
+		now := time.Now()
+		for key, b := range s.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= b.limit.Burst {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// RateLimit middleware con token buckets por IP, usuario y cliente. Debe
+// montarse después de ClientValidation y JWTAuth para conocer cliente y usuario.
+func RateLimit() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			category := classifyRequest(r)
+
+			// Login: solo por IP (todavía no hay usuario autenticado)
+			if category == limitLogin {
+				cfg := config.Load()
+				if !applyLimit(w, limitLogin, "", "ip:"+ClientIP(r), perMinute(cfg.LoginAttemptsPerMinute)) {
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			clientID := GetClientFromContext(r.Context())
+			clientConfig, exists := config.GetClientConfig(clientID)
+			if !exists {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limits := clientConfig.GetRateLimit()
+			identity := rateLimitIdentity(r)
+
+			// Presupuesto compartido por todo el cliente
+			if limits.ClientRequestsPerMinute > 0 {
+				if !applyLimit(w, limitClient, clientID, "", perMinute(limits.ClientRequestsPerMinute)) {
+					return
+				}
+			}
+
+			var limit Limit
+			switch category {
+			case limitUpload:
+				limit = perMinute(limits.UploadsPerMinute)
+			case limitDownload:
+				limit = perMinute(limits.DownloadsPerMinute)
+			default:
+				limit = perMinute(limits.RequestsPerMinute)
+			}
+			if !applyLimit(w, category, clientID, identity, limit) {
+				return
+			}
+
+			// Ancho de banda de subida: se frena la lectura del body en vez de rechazar
+			if category == limitUpload && limits.UploadBytesPerSecond > 0 {
+				rate := float64(limits.UploadBytesPerSecond)
+				r.Body = &throttledReader{
+					ReadCloser: r.Body,
+					request:    r,
+					key:        limitUploadBytes + ":" + clientID + ":" + identity,
+					limit:      Limit{Rate: rate, Burst: rate},
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IPRateLimit middleware que limita por IP antes de autenticar, para que las
+// requests sin token o con token inválido también consuman presupuesto. Debe
+// montarse después de ClientValidation y antes de JWTAuth; usa RequestsPerMinute
+// del cliente para todas las categorías.
+func IPRateLimit() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID := GetClientFromContext(r.Context())
+			clientConfig, exists := config.GetClientConfig(clientID)
+			if !exists {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limit := perMinute(clientConfig.GetRateLimit().RequestsPerMinute)
+			if !applyLimit(w, limitIP, clientID, "ip:"+ClientIP(r), limit) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// classifyRequest determina la categoría de límite de una request
+func classifyRequest(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case path == "/api/login":
+		return limitLogin
+	case path == "/api/files/upload" && r.Method == http.MethodPost:
+		return limitUpload
+	case strings.HasPrefix(path, "/api/files/download/"), path == "/api/files/archive", strings.HasPrefix(path, "/static/"),
+		strings.HasPrefix(path, "/api/files/transform/"), strings.HasPrefix(path, "/api/files/preview/"):
+		return limitDownload
+	default:
+		return limitAPI
+	}
+}
+
+// rateLimitIdentity identifica a quien hace la request: usuario si está autenticado, si no IP
+func rateLimitIdentity(r *http.Request) string {
+	if userID := GetUserFromContext(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + ClientIP(r)
+}
+
+// applyLimit consume un token del bucket category:clientID:identity y escribe los
+// headers X-RateLimit-*. Si no hay tokens responde 429 con Retry-After y retorna false.
+func applyLimit(w http.ResponseWriter, category, clientID, identity string, limit Limit) bool {
+	if limit.Rate <= 0 {
+		return true
+	}
+
+	result := rateLimitStore.Allow(category+":"+clientID+":"+identity, limit, 1)
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(int(limit.Burst)))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(result.Remaining)))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.ResetAfter.Seconds()))))
+
+	if result.Allowed {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	if clientID == "" {
+		clientID = "none"
+	}
+	metrics.RateLimitRejections.WithLabelValues(category, clientID).Inc()
+
+	errorResponse := models.ErrorResponse{
+		Success:   false,
+		Error:     "Demasiadas solicitudes, intente nuevamente en " + strconv.Itoa(retryAfter) + " segundos",
+		Code:      http.StatusTooManyRequests,
+		RequestID: w.Header().Get(RequestIDHeader),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(errorResponse)
+	return false
+}
+
+// throttledReader limita los bytes por segundo leídos del body de una request
+type throttledReader struct {
+	io.ReadCloser
+	request *http.Request
+	key     string
+	limit   Limit
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// Nunca pedir más tokens que la capacidad del bucket
+	if max := int(t.limit.Burst); len(p) > max {
+		p = p[:max]
+	}
+
+	n, err := t.ReadCloser.Read(p)
+	if n <= 0 {
+		return n, err
+	}
+
+	for {
+		result := rateLimitStore.Allow(t.key, t.limit, float64(n))
+		if result.Allowed {
+			return n, err
+		}
+		select {
+		case <-t.request.Context().Done():
+			return n, t.request.Context().Err()
+		case <-time.After(result.RetryAfter):
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader es el header con el que se recibe y propaga el ID de la request
+const RequestIDHeader = "X-Request-Id"
+
+// requestInfo acompaña a la request durante toda la cadena de middleware.
+// Es un puntero para que los datos que se conocen más adentro (cliente, usuario)
+// queden visibles para el log de acceso que se escribe al final.
+type requestInfo struct {
+	mu       sync.Mutex
+	id       string
+	ip       string
+	clientID string
+	userID   string
+}
+
+// RequestID middleware que asigna un ID a cada request. Respeta el X-Request-Id
+// recibido (por ejemplo de nginx) si es válido y lo devuelve en la respuesta.
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			info := &requestInfo{id: id, ip: ClientIP(r)}
+			ctx := context.WithValue(r.Context(), "requestInfo", info)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// validRequestID acepta IDs cortos con caracteres seguros para logs y headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func getRequestInfo(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value("requestInfo").(*requestInfo)
+	return info
+}
+
+// setRequestClient registra el cliente de la request para el log de acceso
+func setRequestClient(ctx context.Context, clientID string) {
+	if info := getRequestInfo(ctx); info != nil {
+		info.mu.Lock()
+		info.clientID = clientID
+		info.mu.Unlock()
+	}
+}
+
+// setRequestUser registra el usuario autenticado para el log de acceso
+func setRequestUser(ctx context.Context, userID string) {
+	if info := getRequestInfo(ctx); info != nil {
+		info.mu.Lock()
+		info.userID = userID
+		info.mu.Unlock()
+	}
+}
+
+// GetRequestIDFromContext obtiene el ID de la request del contexto
+func GetRequestIDFromContext(ctx context.Context) string {
+	if info := getRequestInfo(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// Logger retorna un logger con el ID de la request, la IP, el cliente y el usuario
+func Logger(ctx context.Context) *slog.Logger {
+	info := getRequestInfo(ctx)
+	if info == nil {
+		return slog.Default()
+	}
+
+	info.mu.Lock()
+	defer info.mu.Unlock()
+
+	attrs := []any{"request_id", info.id, "ip", info.ip}
+	if info.clientID != "" {
+		attrs = append(attrs, "client", info.clientID)
+	}
+	if info.userID != "" {
+		attrs = append(attrs, "user", info.userID)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		attrs = append(attrs, "trace_id", spanContext.TraceID().String())
+	}
+	return slog.Default().With(attrs...)
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/models"
+)
+
+// AuthCookieName es la cookie con el JWT que se envía al hacer login, para que
+// <img src="/static/..."> funcione en el navegador sin header Authorization
+const AuthCookieName = "fs_token"
+
+// StaticAuth middleware para las URLs /static/{client}/{carpeta}/{archivo}.
+// Toma el cliente del path y, si requiere autenticación, acepta bearer token,
+// certificado de cliente, la cookie de sesión o una URL firmada vigente.
+func StaticAuth() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID := StaticClient(r.URL.Path)
+			clientConfig, exists := config.GetClientConfig(clientID)
+			if !exists {
+				errorResponse := models.ErrorResponse{
+					Success:   false,
+					Error:     "Archivo no encontrado",
+					Code:      http.StatusNotFound,
+					RequestID: w.Header().Get(RequestIDHeader),
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(errorResponse)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "clientID", clientID)
+			setRequestClient(ctx, clientID)
+
+			if !clientConfig.RequiresAuth {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			// URL firmada: el acceso vale hasta la fecha de expiración
+			if r.URL.Query().Has("signature") {
+				expires, ok := verifyStaticSignature(r)
+				if !ok {
+					unauthorizedResponse(w, "signature_invalid", "URL firmada inválida o expirada")
+					return
+				}
+				ctx = context.WithValue(ctx, "signedURLExpires", expires)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			// Header Authorization o certificado de cliente
+			if err := AuthorizeClient(r, clientID); err == nil {
+				if token := extractToken(r); token != "" {
+					userID, _ := validateJWTToken(token)
+					ctx = context.WithValue(ctx, "userID", userID)
+					setRequestUser(ctx, userID)
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			// Cookie de sesión del navegador
+			cookie, err := r.Cookie(AuthCookieName)
+			if err != nil || cookie.Value == "" {
+				unauthorizedResponse(w, "token_missing", "Token de autenticación requerido")
+				return
+			}
+			userID, err := validateJWTToken(cookie.Value)
+			if err != nil {
+				unauthorizedResponse(w, "token_invalid", "Token inválido: "+err.Error())
+				return
+			}
+			ctx = context.WithValue(ctx, "userID", userID)
+			setRequestUser(ctx, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// StaticClient retorna el cliente de una URL /static/{client}/...
+func StaticClient(urlPath string) string {
+	parts := strings.SplitN(strings.TrimPrefix(urlPath, "/static/"), "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0]
+}
+
+// SignStaticURL agrega a una URL /static la expiración y la firma HMAC que
+// permiten compartirla sin token hasta expires
+func SignStaticURL(urlPath string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", exp)
+	query.Set("signature", staticSignature(urlPath, exp))
+	return urlPath + "?" + query.Encode()
+}
+
+// verifyStaticSignature valida la firma y la expiración de una URL firmada
+func verifyStaticSignature(r *http.Request) (time.Time, bool) {
+	query := r.URL.Query()
+	exp := query.Get("expires")
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	expires := time.Unix(unix, 0)
+	if time.Now().After(expires) {
+		return time.Time{}, false
+	}
+
+	expected := staticSignature(r.URL.Path, exp)
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(expected)) {
+		return time.Time{}, false
+	}
+	return expires, true
+}
+
+// staticSignature calcula la firma de un path y su expiración con el secreto del servidor
+func staticSignature(urlPath, expires string) string {
+	cfg := config.Load()
+	mac := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+	mac.Write([]byte("static\n" + urlPath + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// GetSignedURLExpiry retorna la expiración de la URL firmada con la que se
+// accedió a un archivo estático (ok=false si se usó token o el cliente es público)
+func GetSignedURLExpiry(ctx context.Context) (time.Time, bool) {
+	expires, ok := ctx.Value("signedURLExpires").(time.Time)
+	return expires, ok
+}
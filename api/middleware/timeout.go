@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// BodyIdleTimeout middleware que corta la conexión si el cliente deja de enviar
+// el body durante más de timeout. A diferencia de http.Server.ReadTimeout no
+// limita la duración total, así que no afecta subidas grandes pero lentas.
+func BodyIdleTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timeout > 0 && r.Body != nil && r.Body != http.NoBody {
+				r.Body = &idleTimeoutReader{
+					ReadCloser: r.Body,
+					controller: http.NewResponseController(w),
+					timeout:    timeout,
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// idleTimeoutReader extiende el deadline de lectura de la conexión antes de cada Read
+type idleTimeoutReader struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	r.controller.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.ReadCloser.Read(p)
+}
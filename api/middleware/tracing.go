@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"file-server-sofmar/tracing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing middleware que crea el span raíz de cada request, continuando la traza
+// recibida en los headers W3C (traceparent/tracestate). Debe montarse después de
+// RequestID y antes del resto para que los demás spans queden como hijos.
+func Tracing() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			route := routeTemplate(r)
+			ctx, span := tracing.Start(ctx, r.Method+" "+route,
+				attribute.String("http.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("http.target", r.URL.RequestURI()),
+				attribute.String("client.address", ClientIP(r)),
+				attribute.String("request.id", GetRequestIDFromContext(r.Context())),
+			)
+			defer span.End()
+
+			wrapper := &responseWrapper{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			next.ServeHTTP(wrapper, r.WithContext(ctx))
+
+			span.SetAttributes(
+				attribute.Int("http.status_code", wrapper.statusCode),
+				attribute.Int("http.response_size", wrapper.bytesWritten),
+			)
+			if info := getRequestInfo(r.Context()); info != nil {
+				info.mu.Lock()
+				if info.clientID != "" {
+					span.SetAttributes(tracing.Client(info.clientID))
+				}
+				if info.userID != "" {
+					span.SetAttributes(attribute.String("user.id", info.userID))
+				}
+				info.mu.Unlock()
+			}
+			if wrapper.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(wrapper.statusCode))
+			}
+		})
+	}
+}
+
+// tracedParentKey es la clave de contexto del span padre de Traced. Es un tipo
+// propio para que no pueda pisarse con valores de otros paquetes.
+type tracedParentKey struct{}
+
+// Traced envuelve un middleware en un span propio que cubre solo su trabajo:
+// el span se cierra al pasar al siguiente handler o al responder directamente.
+func Traced(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		inner := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace.SpanFromContext(r.Context()).End()
+
+			// Continuar con el span padre como activo, conservando los valores
+			// que el middleware agregó al contexto
+			parent, _ := r.Context().Value(tracedParentKey{}).(trace.Span)
+			next.ServeHTTP(w, r.WithContext(trace.ContextWithSpan(r.Context(), parent)))
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := trace.SpanFromContext(r.Context())
+			ctx, span := tracing.Start(r.Context(), name)
+			defer span.End()
+
+			ctx = context.WithValue(ctx, tracedParentKey{}, parent)
+			inner.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// HandlerSpan middleware que crea un span para el handler de la ruta.
+// Debe ser el último middleware del subrouter.
+func HandlerSpan() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.Start(r.Context(), "handler "+routeTemplate(r))
+			defer span.End()
+
+			if clientID := GetClientFromContext(ctx); clientID != "" {
+				span.SetAttributes(tracing.Client(clientID))
+			}
+			if fileID := mux.Vars(r)["fileId"]; fileID != "" {
+				span.SetAttributes(tracing.File(fileID))
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// routeTemplate retorna la plantilla de la ruta de mux (sin IDs concretos)
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
@@ -6,18 +6,74 @@ import (
 
 // FileMetadata representa la información de un archivo en el sistema
 type FileMetadata struct {
-	FileID      string    `json:"fileId"`
-	OriginalName string   `json:"originalName"`
-	FileName    string    `json:"fileName"`
-	Client      string    `json:"client"`
-	Folder      string    `json:"folder,omitempty"`
-	Size        int64     `json:"size"`
-	MimeType    string    `json:"mimeType"`
-	Extension   string    `json:"extension"`
-	UploadedAt  time.Time `json:"uploadedAt"`
-	URL         string    `json:"url"`
-	Path        string    `json:"path"`
-	Hash        string    `json:"hash,omitempty"`
+	FileID       string                 `json:"fileId"`
+	OriginalName string                 `json:"originalName"`
+	FileName     string                 `json:"fileName"`
+	Client       string                 `json:"client"`
+	Folder       string                 `json:"folder,omitempty"`
+	Size         int64                  `json:"size"`
+	MimeType     string                 `json:"mimeType"`
+	Extension    string                 `json:"extension"`
+	UploadedAt   time.Time              `json:"uploadedAt"`
+	URL          string                 `json:"url"`
+	Path         string                 `json:"path"`
+	Hash         string                 `json:"hash,omitempty"`
+	Thumbnails   map[string]string      `json:"thumbnails,omitempty"` // URL de miniatura por tamaño
+	Tags         []string               `json:"tags,omitempty"`
+	Custom       map[string]interface{} `json:"custom,omitempty"`
+	Extended     *ExtendedMetadata      `json:"extended,omitempty"` // metadata embebida en el archivo
+	Scan         *ScanResult            `json:"scan,omitempty"`     // análisis antivirus (clientes con MalwareScan)
+}
+
+// Estados del análisis antivirus de un archivo
+const (
+	ScanClean    = "clean"    // analizado sin detecciones
+	ScanInfected = "infected" // malware detectado: el archivo está en cuarentena
+	ScanPending  = "pending"  // el antivirus no respondió: bloqueado hasta reintentar
+)
+
+// ScanResult es el resultado del análisis antivirus de un archivo
+type ScanResult struct {
+	Status    string     `json:"status"`
+	Signature string     `json:"signature,omitempty"` // firma detectada
+	ScannedAt *time.Time `json:"scannedAt,omitempty"`
+	Error     string     `json:"error,omitempty"` // motivo por el que quedó pendiente
+}
+
+// ExtendedMetadata es la metadata propia del formato que se extrae al subir el
+// archivo (EXIF de imágenes, propiedades de documentos, duración de audio/video).
+// Solo se completan los campos que el formato tiene.
+type ExtendedMetadata struct {
+	// Imágenes
+	Width       int             `json:"width,omitempty"`
+	Height      int             `json:"height,omitempty"`
+	Orientation int             `json:"orientation,omitempty"` // valor EXIF 1-8
+	CameraMake  string          `json:"cameraMake,omitempty"`
+	CameraModel string          `json:"cameraModel,omitempty"`
+	TakenAt     *time.Time      `json:"takenAt,omitempty"`
+	GPS         *GPSCoordinates `json:"gps,omitempty"`
+	// Documentos
+	Pages  int      `json:"pages,omitempty"`
+	Title  string   `json:"title,omitempty"`
+	Author string   `json:"author,omitempty"`
+	Sheets []string `json:"sheets,omitempty"`
+	// Audio y video
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+}
+
+// PreviewTable son las primeras filas de un CSV o una hoja de cálculo
+type PreviewTable struct {
+	Sheet     string     `json:"sheet,omitempty"`  // hoja mostrada (XLSX)
+	Sheets    []string   `json:"sheets,omitempty"` // hojas disponibles (XLSX)
+	Rows      [][]string `json:"rows"`
+	Truncated bool       `json:"truncated"` // el archivo tiene más filas
+}
+
+// GPSCoordinates es la ubicación registrada en el EXIF de una foto
+type GPSCoordinates struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
 }
 
 // UploadResponse representa la respuesta de una subida exitosa
@@ -34,31 +90,82 @@ type ListResponse struct {
 	Data    []FileMetadata `json:"data,omitempty"`
 	Count   int            `json:"count"`
 	Error   string         `json:"error,omitempty"`
+	// Cursores opacos para pedir la página siguiente/anterior (parámetro cursor)
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	// Resultados de búsqueda por contenido, por fileId
+	Matches map[string]ContentMatch `json:"matches,omitempty"`
+}
+
+// ContentMatch representa la relevancia y los fragmentos resaltados de un
+// archivo encontrado por búsqueda de contenido
+type ContentMatch struct {
+	Score    float64  `json:"score"`
+	Snippets []string `json:"snippets,omitempty"`
 }
 
 // ErrorResponse representa una respuesta de error
 type ErrorResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
-	Code    int    `json:"code,omitempty"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+	Code      int    `json:"code,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// SignedURLResponse representa una URL /static firmada para compartir un archivo
+type SignedURLResponse struct {
+	Success   bool      `json:"success"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 // SearchRequest representa una solicitud de búsqueda
 type SearchRequest struct {
-	Query    string   `json:"query"`
-	Types    []string `json:"types,omitempty"`
-	MinSize  int64    `json:"minSize,omitempty"`
-	MaxSize  int64    `json:"maxSize,omitempty"`
-	DateFrom string   `json:"dateFrom,omitempty"`
-	DateTo   string   `json:"dateTo,omitempty"`
+	Query    string            `json:"query"`
+	Content  string            `json:"content,omitempty"` // Búsqueda en el texto de los documentos
+	Types    []string          `json:"types,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`   // Todos los tags indicados son obligatorios
+	Custom   map[string]string `json:"custom,omitempty"` // Igualdad sobre campos personalizados
+	MinSize  int64             `json:"minSize,omitempty"`
+	MaxSize  int64             `json:"maxSize,omitempty"`
+	DateFrom string            `json:"dateFrom,omitempty"`
+	DateTo   string            `json:"dateTo,omitempty"`
+	Sort     string            `json:"sort,omitempty"`
+	Order    string            `json:"order,omitempty"`
+	Limit    int               `json:"limit,omitempty"`
+	Offset   int               `json:"offset,omitempty"`
+	Cursor   string            `json:"cursor,omitempty"`
+}
+
+// MetadataUpdateRequest representa una actualización de tags y campos personalizados.
+// Tags reemplaza la lista completa si se envía; Custom se combina con los valores
+// existentes y un valor null elimina el campo.
+type MetadataUpdateRequest struct {
+	Tags   *[]string              `json:"tags,omitempty"`
+	Custom map[string]interface{} `json:"custom,omitempty"`
+}
+
+// ArchiveRequest indica qué archivos incluir en una descarga masiva: una lista
+// de fileIds, una carpeta (incluye subcarpetas) o una búsqueda
+type ArchiveRequest struct {
+	FileIDs []string       `json:"fileIds,omitempty"`
+	Folder  *string        `json:"folder,omitempty"` // "" = todo el cliente
+	Search  *SearchRequest `json:"search,omitempty"`
+	Format  string         `json:"format,omitempty"` // zip (default) o tar.gz
+	Name    string         `json:"name,omitempty"`   // nombre del archivo sin extensión
+}
+
+// CopyRequest representa una solicitud de copia de archivo en el servidor
+type CopyRequest struct {
+	TargetClient string `json:"targetClient,omitempty"`
+	TargetFolder string `json:"targetFolder,omitempty"`
 }
 
 // HealthResponse representa la respuesta del health check
 type HealthResponse struct {
-	Status  string            `json:"status"`
-	Service string            `json:"service"`
-	Version string            `json:"version"`
-	Uptime  string            `json:"uptime,omitempty"`
+	Status  string                 `json:"status"`
+	Service string                 `json:"service"`
+	Version string                 `json:"version"`
+	Uptime  string                 `json:"uptime,omitempty"`
 	Stats   map[string]interface{} `json:"stats,omitempty"`
 }
-
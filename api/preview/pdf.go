@@ -0,0 +1,161 @@
+package preview
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ErrPageNotFound indica que el PDF no tiene la página pedida
+var ErrPageNotFound = errors.New("la página no existe")
+
+// pdfScale son los píxeles por punto de la página renderizada (1.5 = 108 dpi)
+const pdfScale = 1.5
+
+// maxPageSide limita el tamaño de la imagen para páginas gigantes (planos)
+const maxPageSide = 2000
+
+// PDFPage escribe como PNG una vista aproximada de una página: el texto en su
+// posición y los rectángulos (bordes de tablas). No hay un renderizador de PDF
+// en Go puro, así que imágenes, curvas y fuentes no se dibujan. Retorna la
+// cantidad de páginas del PDF.
+func PDFPage(w io.Writer, path string, pageNum int) (pages int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("PDF no legible: %v", r)
+		}
+	}()
+
+	file, reader, err := pdf.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	pages = reader.NumPage()
+	if pageNum < 1 || pageNum > pages {
+		return pages, fmt.Errorf("%w: %d (el PDF tiene %d)", ErrPageNotFound, pageNum, pages)
+	}
+	page := reader.Page(pageNum)
+	if page.V.IsNull() {
+		return pages, fmt.Errorf("%w: %d", ErrPageNotFound, pageNum)
+	}
+
+	box := mediaBox(page)
+	scale := pdfScale
+	if side := max(box.Dx(), box.Dy()) * scale; side > maxPageSide {
+		scale = maxPageSide / max(box.Dx(), box.Dy())
+	}
+	width := max(1, int(box.Dx()*scale))
+	height := max(1, int(box.Dy()*scale))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	// Coordenadas PDF: origen abajo a la izquierda. Las coordenadas vienen del
+	// archivo: NaN o infinito se descartan y el resto se acota para que la
+	// conversión a int no desborde.
+	toPixel := func(x, y float64) (int, int, bool) {
+		px, py := (x-box.minX)*scale, (box.maxY-y)*scale
+		if math.IsNaN(px) || math.IsNaN(py) || math.IsInf(px, 0) || math.IsInf(py, 0) {
+			return 0, 0, false
+		}
+		return clampPixel(px), clampPixel(py), true
+	}
+
+	// Una página sin Contents (en blanco) hace entrar en pánico a Content()
+	var content pdf.Content
+	if !page.V.Key("Contents").IsNull() {
+		content = page.Content()
+	}
+	lineColor := color.RGBA{0x99, 0x99, 0x99, 0xff}
+	for _, rect := range content.Rect {
+		x0, y1, ok0 := toPixel(rect.Min.X, rect.Min.Y)
+		x1, y0, ok1 := toPixel(rect.Max.X, rect.Max.Y)
+		if !ok0 || !ok1 {
+			continue
+		}
+		strokeRect(img, image.Rect(x0, y0, x1, y1), lineColor)
+	}
+
+	// La librería entrega un elemento por glifo. Con fuentes estándar sin /Widths
+	// todos los glifos de una línea llegan en la misma X, así que en la misma
+	// línea nunca se retrocede respecto al glifo anterior.
+	drawer := &font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	for _, text := range content.Text {
+		x, y, ok := toPixel(text.X, text.Y)
+		if !ok {
+			continue
+		}
+		dot := fixed.P(x, y)
+		if dot.Y == drawer.Dot.Y && dot.X < drawer.Dot.X {
+			dot.X = drawer.Dot.X
+		}
+		drawer.Dot = dot
+		drawer.DrawString(text.S)
+	}
+
+	return pages, png.Encode(w, img)
+}
+
+// pageBox es el MediaBox de una página en puntos
+type pageBox struct {
+	minX, minY, maxX, maxY float64
+}
+
+func (b pageBox) Dx() float64 { return b.maxX - b.minX }
+func (b pageBox) Dy() float64 { return b.maxY - b.minY }
+
+// mediaBox retorna el tamaño de la página, heredado de las páginas padre si no
+// está en la página (A4 si el PDF no lo declara)
+func mediaBox(page pdf.Page) pageBox {
+	for v := page.V; !v.IsNull(); v = v.Key("Parent") {
+		box := v.Key("MediaBox")
+		if box.Len() != 4 {
+			continue
+		}
+		b := pageBox{box.Index(0).Float64(), box.Index(1).Float64(), box.Index(2).Float64(), box.Index(3).Float64()}
+		if b.Dx() > 0 && b.Dy() > 0 {
+			return b
+		}
+	}
+	return pageBox{0, 0, 595, 842}
+}
+
+// maxPixel acota las coordenadas en píxeles: muy por encima de cualquier página
+// renderizada, pero lejos del desborde de int
+const maxPixel = 1 << 20
+
+// clampPixel convierte una coordenada a píxeles dentro de ±maxPixel
+func clampPixel(v float64) int {
+	return int(math.Max(-maxPixel, math.Min(maxPixel, v)))
+}
+
+// strokeRect dibuja el contorno de un rectángulo. Solo recorre la parte que cae
+// dentro de la imagen: un PDF puede declarar rectángulos enormes.
+func strokeRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	r = r.Canon()
+	// Los bordes son inclusivos, por eso se suma 1 al máximo
+	clip := image.Rect(r.Min.X, r.Min.Y, r.Max.X+1, r.Max.Y+1).Intersect(img.Bounds())
+	if clip.Empty() {
+		return
+	}
+	for x := clip.Min.X; x < clip.Max.X; x++ {
+		img.Set(x, r.Min.Y, c)
+		img.Set(x, r.Max.Y, c)
+	}
+	for y := clip.Min.Y; y < clip.Max.Y; y++ {
+		img.Set(r.Min.X, y, c)
+		img.Set(r.Max.X, y, c)
+	}
+}
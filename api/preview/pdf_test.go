@@ -0,0 +1,81 @@
+package preview
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStrokeRectClipsToImage(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	tests := []struct {
+		name    string
+		rect    image.Rectangle
+		painted []image.Point
+		blank   []image.Point
+	}{
+		{
+			name:    "dentro de la imagen",
+			rect:    image.Rect(2, 2, 5, 5),
+			painted: []image.Point{{2, 2}, {5, 2}, {2, 5}, {5, 5}, {3, 2}},
+			blank:   []image.Point{{3, 3}, {6, 6}},
+		},
+		{
+			name:    "invertido",
+			rect:    image.Rect(5, 5, 2, 2),
+			painted: []image.Point{{2, 2}, {5, 5}},
+		},
+		{
+			name:    "enorme: solo los bordes visibles",
+			rect:    image.Rect(-maxPixel, 3, maxPixel, maxPixel),
+			painted: []image.Point{{0, 3}, {9, 3}},
+			blank:   []image.Point{{0, 4}, {9, 9}},
+		},
+		{
+			name:  "fuera de la imagen",
+			rect:  image.Rect(20, 20, maxPixel, maxPixel),
+			blank: []image.Point{{9, 9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+			start := time.Now()
+			strokeRect(img, tt.rect, red)
+			if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+				t.Errorf("strokeRect tardó %s", elapsed)
+			}
+			for _, p := range tt.painted {
+				if img.RGBAAt(p.X, p.Y) != red {
+					t.Errorf("píxel %v sin pintar", p)
+				}
+			}
+			for _, p := range tt.blank {
+				if img.RGBAAt(p.X, p.Y) == red {
+					t.Errorf("píxel %v pintado", p)
+				}
+			}
+		})
+	}
+}
+
+func TestClampPixel(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want int
+	}{
+		{12.7, 12},
+		{-3.2, -3},
+		{1e12, maxPixel},
+		{-1e300, -maxPixel},
+		{math.Inf(1), maxPixel},
+	}
+	for _, tt := range tests {
+		if got := clampPixel(tt.in); got != tt.want {
+			t.Errorf("clampPixel(%g) = %d, esperado %d", tt.in, got, tt.want)
+		}
+	}
+}
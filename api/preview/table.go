@@ -0,0 +1,298 @@
+package preview
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"file-server-sofmar/models"
+)
+
+// ErrSheetNotFound indica que la planilla no tiene la hoja pedida
+var ErrSheetNotFound = errors.New("hoja no encontrada")
+
+// maxXMLSize limita lo que se lee de cada XML de una planilla
+const maxXMLSize = 64 * 1024 * 1024
+
+// maxEmptyColumns es el máximo de columnas vacías que se completan entre dos celdas
+const maxEmptyColumns = 1000
+
+// CSV retorna las primeras n filas de un CSV o TSV. El separador de CSV se
+// detecta en la primera línea (Excel en español exporta con ";").
+func CSV(filePath, ext string, n int) (*models.PreviewTable, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	comma := '\t'
+	if strings.ToLower(ext) != ".tsv" {
+		first, _ := reader.Peek(4096)
+		comma = ','
+		if strings.Count(string(first), ";") > strings.Count(string(first), ",") {
+			comma = ';'
+		}
+	}
+
+	r := csv.NewReader(reader)
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	table := &models.PreviewTable{Rows: [][]string{}}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV inválido: %v", err)
+		}
+		if len(table.Rows) == n {
+			table.Truncated = true
+			return table, nil
+		}
+		for i := range record {
+			record[i] = strings.ToValidUTF8(record[i], "�")
+		}
+		table.Rows = append(table.Rows, record)
+	}
+}
+
+// XLSX retorna las primeras n filas de una hoja (la primera si sheet está vacío)
+func XLSX(filePath, sheet string, n int) (*models.PreviewTable, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	sheets, err := workbookSheets(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("la planilla no tiene hojas")
+	}
+
+	table := &models.PreviewTable{Rows: [][]string{}}
+	var target string
+	for _, s := range sheets {
+		table.Sheets = append(table.Sheets, s.name)
+		if target == "" && (sheet == "" || s.name == sheet) {
+			table.Sheet, target = s.name, s.target
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("%w: %s", ErrSheetNotFound, sheet)
+	}
+
+	shared, err := sharedStrings(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := readSheetRows(&zr.Reader, target, shared, n, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+type sheetRef struct {
+	name   string
+	target string
+}
+
+// workbookSheets retorna las hojas en orden con la ruta de su XML dentro del ZIP
+func workbookSheets(zr *zip.Reader) ([]sheetRef, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeZipXML(zr, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	targets := make(map[string]string)
+	if err := decodeZipXML(zr, "xl/_rels/workbook.xml.rels", &rels); err == nil {
+		for _, rel := range rels.Relationships {
+			target := rel.Target
+			if strings.HasPrefix(target, "/") {
+				target = strings.TrimPrefix(target, "/")
+			} else {
+				target = path.Join("xl", target)
+			}
+			targets[rel.ID] = target
+		}
+	}
+
+	sheets := make([]sheetRef, 0, len(workbook.Sheets))
+	for i, s := range workbook.Sheets {
+		target, ok := targets[s.RID]
+		if !ok {
+			target = fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+		}
+		sheets = append(sheets, sheetRef{name: s.Name, target: target})
+	}
+	return sheets, nil
+}
+
+// sharedStrings lee la tabla de textos compartidos (puede no existir)
+func sharedStrings(zr *zip.Reader) ([]string, error) {
+	var sst struct {
+		Items []struct {
+			T string `xml:"t"`
+			R []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := decodeZipXML(zr, "xl/sharedStrings.xml", &sst); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	shared := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		text := item.T
+		for _, run := range item.R { // texto con formato enriquecido
+			text += run.T
+		}
+		shared[i] = text
+	}
+	return shared, nil
+}
+
+// xlsxCell es una celda de sheetData
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		T string `xml:"t"`
+	} `xml:"is"`
+}
+
+// readSheetRows recorre las filas de la hoja sin cargar el XML completo
+func readSheetRows(zr *zip.Reader, name string, shared []string, n int, table *models.PreviewTable) error {
+	f, err := openZipFile(zr, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(f, maxXMLSize))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("hoja inválida: %v", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		if len(table.Rows) == n {
+			table.Truncated = true
+			return nil
+		}
+
+		var row struct {
+			Cells []xlsxCell `xml:"c"`
+		}
+		if err := decoder.DecodeElement(&row, &start); err != nil {
+			return fmt.Errorf("hoja inválida: %v", err)
+		}
+
+		// Las celdas vacías no aparecen en el XML: se ubican por su referencia
+		values := []string{}
+		for _, cell := range row.Cells {
+			col := columnIndex(cell.Ref)
+			if col < len(values) || col > len(values)+maxEmptyColumns {
+				col = len(values)
+			}
+			for len(values) < col {
+				values = append(values, "")
+			}
+			values = append(values, cellValue(cell, shared))
+		}
+		table.Rows = append(table.Rows, values)
+	}
+}
+
+// cellValue resuelve el texto de una celda según su tipo
+func cellValue(cell xlsxCell, shared []string) string {
+	switch cell.Type {
+	case "s":
+		if i, err := strconv.Atoi(cell.Value); err == nil && i >= 0 && i < len(shared) {
+			return shared[i]
+		}
+		return ""
+	case "inlineStr":
+		return cell.Inline.T
+	case "b":
+		if cell.Value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return cell.Value
+}
+
+// columnIndex convierte la referencia de una celda ("C7") en el índice de columna (2)
+func columnIndex(ref string) int {
+	col := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		letters++
+	}
+	if letters == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+// openZipFile abre un archivo dentro del ZIP (os.ErrNotExist si no está)
+func openZipFile(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+// decodeZipXML decodifica un XML dentro del ZIP
+func decodeZipXML(zr *zip.Reader, name string, v interface{}) error {
+	f, err := openZipFile(zr, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(io.LimitReader(f, maxXMLSize)).Decode(v)
+}
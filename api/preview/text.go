@@ -0,0 +1,93 @@
+package preview
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxLineSize corta las líneas muy largas (archivos minificados o sin saltos de línea)
+const maxLineSize = 64 * 1024
+
+// Language es el lenguaje de un archivo de texto y el Content-Type con el que
+// se devuelve su vista previa
+type Language struct {
+	Name        string
+	ContentType string
+}
+
+// textLanguages son los archivos de texto con vista previa. HTML, SVG, XML y
+// JavaScript se devuelven como text/plain para que el navegador no los ejecute.
+var textLanguages = map[string]Language{
+	".txt":  {"text", "text/plain; charset=utf-8"},
+	".log":  {"log", "text/plain; charset=utf-8"},
+	".md":   {"markdown", "text/markdown; charset=utf-8"},
+	".csv":  {"csv", "text/csv; charset=utf-8"},
+	".tsv":  {"tsv", "text/tab-separated-values; charset=utf-8"},
+	".json": {"json", "application/json; charset=utf-8"},
+	".yaml": {"yaml", "application/yaml; charset=utf-8"},
+	".yml":  {"yaml", "application/yaml; charset=utf-8"},
+	".ini":  {"ini", "text/plain; charset=utf-8"},
+	".sql":  {"sql", "application/sql; charset=utf-8"},
+	".xml":  {"xml", "text/plain; charset=utf-8"},
+	".html": {"html", "text/plain; charset=utf-8"},
+	".htm":  {"html", "text/plain; charset=utf-8"},
+	".svg":  {"svg", "text/plain; charset=utf-8"},
+	".js":   {"javascript", "text/plain; charset=utf-8"},
+	".css":  {"css", "text/css; charset=utf-8"},
+}
+
+// TextLanguage retorna el lenguaje de un archivo de texto (ok=false si no tiene vista previa)
+func TextLanguage(ext string) (Language, bool) {
+	lang, ok := textLanguages[strings.ToLower(ext)]
+	return lang, ok
+}
+
+// Lines retorna las primeras n líneas de un archivo de texto. truncated indica
+// que el archivo tiene más líneas. El contenido que no es UTF-8 válido se reemplaza.
+func Lines(path string, n int) (lines []string, truncated bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			return lines, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if len(lines) == n {
+			return lines, true, nil
+		}
+		lines = append(lines, strings.ToValidUTF8(line, "�"))
+	}
+}
+
+// readLine lee una línea sin el salto de línea, cortándola en maxLineSize
+func readLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			if err == io.EOF && line != nil {
+				return string(line), nil
+			}
+			return "", err
+		}
+		if len(line) < maxLineSize {
+			line = append(line, chunk[:min(len(chunk), maxLineSize-len(line))]...)
+		}
+		if line == nil {
+			line = []byte{}
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize es el tamaño de cada bloque enviado a clamd. Debe ser menor que
+// StreamMaxLength de clamd.conf (25MB por defecto).
+const chunkSize = 64 * 1024
+
+// Clamd analiza archivos con un daemon de ClamAV usando el comando INSTREAM:
+// el contenido se envía por el socket en bloques, sin que clamd necesite
+// acceso al filesystem del servidor.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd crea un cliente de clamd. address acepta "host:3310",
+// "tcp://host:3310", "unix:/run/clamav/clamd.ctl" o una ruta de socket.
+// timeout limita el análisis completo de un archivo.
+func NewClamd(address string, timeout time.Duration) *Clamd {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "unix:"):
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	}
+	return &Clamd{network: network, address: address, timeout: timeout}
+}
+
+// Scan envía el contenido a clamd y interpreta la respuesta:
+// "stream: OK", "stream: <firma> FOUND" o "<mensaje> ERROR"
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("conectando a clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	sendErr := c.send(conn, r)
+
+	// Si clamd corta la conexión (ej: supera StreamMaxLength) igual responde
+	// el motivo antes de cerrar; si el envío falló de nuestro lado, clamd sigue
+	// esperando datos y no tiene sentido agotar el timeout completo
+	if sendErr != nil {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		if sendErr != nil {
+			return Result{}, fmt.Errorf("enviando a clamd: %w", sendErr)
+		}
+		return Result{}, fmt.Errorf("leyendo respuesta de clamd: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// send escribe el comando INSTREAM, el contenido en bloques precedidos por su
+// largo (uint32 big endian) y un bloque de largo cero que marca el final
+func (c *Clamd) send(conn net.Conn, r io.Reader) error {
+	w := bufio.NewWriterSize(conn, chunkSize+4)
+	// El prefijo "z" indica que el comando termina en NUL
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	var size [4]byte
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, werr := w.Write(size[:]); werr != nil {
+				return werr
+			}
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// parseReply interpreta la respuesta de clamd a INSTREAM
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	status := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case status == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	case strings.HasSuffix(status, " ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSuffix(status, " ERROR"))
+	default:
+		return Result{}, fmt.Errorf("respuesta inesperada de clamd: %q", reply)
+	}
+}
@@ -0,0 +1,275 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd es un clamd mínimo sobre TCP: lee un comando INSTREAM completo,
+// guarda los bloques recibidos y responde con reply (sin responder si está vacío)
+type fakeClamd struct {
+	listener net.Listener
+	reply    string
+	received chan instream
+}
+
+// instream es lo que recibió fakeClamd en una conexión
+type instream struct {
+	command string
+	chunks  []int
+	data    []byte
+	err     error
+}
+
+func startFakeClamd(t *testing.T, reply string) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeClamd{listener: listener, reply: reply, received: make(chan instream, 1)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		got := f.read(bufio.NewReader(conn))
+		f.received <- got
+		if got.err == nil && f.reply != "" {
+			conn.Write([]byte(f.reply))
+		}
+		// Sin respuesta: esperar a que el cliente corte por timeout
+		io.Copy(io.Discard, conn)
+	}()
+	return f
+}
+
+// read interpreta el protocolo: "zINSTREAM\0" y bloques <largo><datos> hasta largo 0
+func (f *fakeClamd) read(r *bufio.Reader) instream {
+	var got instream
+	command, err := r.ReadString(0)
+	if err != nil {
+		got.err = err
+		return got
+	}
+	got.command = command
+
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			got.err = err
+			return got
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n == 0 {
+			return got
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			got.err = err
+			return got
+		}
+		got.chunks = append(got.chunks, int(n))
+		got.data = append(got.data, chunk...)
+	}
+}
+
+func (f *fakeClamd) address() string {
+	return "tcp://" + f.listener.Addr().String()
+}
+
+func TestClamdScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		infected  bool
+		signature string
+		wantErr   string
+	}{
+		{"limpio", "stream: OK\x00", false, "", ""},
+		{"malware", "stream: Win.Test.EICAR_HDB-1 FOUND\x00", true, "Win.Test.EICAR_HDB-1", ""},
+		{"error de clamd", "INSTREAM size limit exceeded. ERROR\x00", false, "", "size limit exceeded"},
+		{"respuesta desconocida", "PONG\x00", false, "", "respuesta inesperada"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := startFakeClamd(t, tt.reply)
+			clamd := NewClamd(fake.address(), 5*time.Second)
+
+			result, err := clamd.Scan(context.Background(), strings.NewReader("contenido de prueba"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Scan() error = %v, esperado que contenga %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan(): %v", err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Errorf("Scan() = %+v, esperado infected=%v signature=%q", result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestClamdScanTimeout(t *testing.T) {
+	fake := startFakeClamd(t, "")
+	clamd := NewClamd(fake.address(), 200*time.Millisecond)
+
+	start := time.Now()
+	_, err := clamd.Scan(context.Background(), strings.NewReader("sin respuesta"))
+	if err == nil {
+		t.Fatal("Scan() sin respuesta de clamd no retornó error")
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Scan() error = %v, esperado un timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Scan() tardó %s con timeout de 200ms", elapsed)
+	}
+}
+
+func TestClamdScanUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	_, err = NewClamd(address, time.Second).Scan(context.Background(), strings.NewReader("x"))
+	if err == nil || !strings.Contains(err.Error(), "conectando a clamd") {
+		t.Errorf("Scan() error = %v, esperado error de conexión", err)
+	}
+}
+
+func TestClamdChunkFraming(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		chunks []int
+	}{
+		{"vacío", 0, nil},
+		{"un byte", 1, []int{1}},
+		{"bloque exacto", chunkSize, []int{chunkSize}},
+		{"bloque y resto", chunkSize + 10, []int{chunkSize, 10}},
+		{"varios bloques", 3*chunkSize + 1, []int{chunkSize, chunkSize, chunkSize, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := startFakeClamd(t, "stream: OK\x00")
+			data := bytes.Repeat([]byte("0123456789abcdef"), tt.size/16+1)[:tt.size]
+
+			if _, err := NewClamd(fake.address(), 5*time.Second).Scan(context.Background(), bytes.NewReader(data)); err != nil {
+				t.Fatalf("Scan(): %v", err)
+			}
+
+			got := <-fake.received
+			if got.err != nil {
+				t.Fatalf("clamd recibió un stream mal formado: %v", got.err)
+			}
+			if got.command != "zINSTREAM\x00" {
+				t.Errorf("comando = %q, esperado %q", got.command, "zINSTREAM\x00")
+			}
+			if !equalInts(got.chunks, tt.chunks) {
+				t.Errorf("bloques = %v, esperado %v", got.chunks, tt.chunks)
+			}
+			if !bytes.Equal(got.data, data) {
+				t.Errorf("contenido recibido distinto del enviado (%d bytes, esperado %d)", len(got.data), len(data))
+			}
+		})
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply     string
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{"stream: OK\x00", false, "", false},
+		{"stream: OK\n", false, "", false},
+		{"stream: Eicar-Signature FOUND\x00", true, "Eicar-Signature", false},
+		{"stream: Doc.Macro.Generic-123 FOUND", true, "Doc.Macro.Generic-123", false},
+		{"Can't allocate memory ERROR\x00", false, "", true},
+		{"", false, "", true},
+		{"stream: OKAY", false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			result, err := parseReply(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReply(%q) error = %v, esperado error: %v", tt.reply, err, tt.wantErr)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Errorf("parseReply(%q) = %+v, esperado infected=%v signature=%q", tt.reply, result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestNewClamdAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		target  string
+	}{
+		{"clamav:3310", "tcp", "clamav:3310"},
+		{"tcp://clamav:3310", "tcp", "clamav:3310"},
+		{"unix:/run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl"},
+		{"unix:///run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl"},
+		{"/run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			c := NewClamd(tt.address, time.Second)
+			if c.network != tt.network || c.address != tt.target {
+				t.Errorf("NewClamd(%q) = %s %s, esperado %s %s", tt.address, c.network, c.address, tt.network, tt.target)
+			}
+		})
+	}
+}
+
+func TestScanFileNotConfigured(t *testing.T) {
+	SetDefault(nil)
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ScanFile(context.Background(), path); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("ScanFile() sin antivirus = %v, esperado ErrNotConfigured", err)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
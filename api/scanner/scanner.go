@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrNotConfigured indica que no hay un antivirus configurado (CLAMD_ADDRESS vacío)
+var ErrNotConfigured = errors.New("antivirus no configurado")
+
+// Result es el resultado del análisis de un archivo
+type Result struct {
+	Infected bool
+	// Signature es el nombre de la firma detectada (ej: "Win.Test.EICAR_HDB-1")
+	Signature string
+}
+
+// Scanner analiza el contenido de un archivo en busca de malware. Un error
+// significa que no se pudo analizar (antivirus caído, timeout), no que el
+// archivo esté infectado.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+var (
+	mu      sync.RWMutex
+	current Scanner
+)
+
+// SetDefault configura el antivirus usado por ScanFile (nil lo deshabilita)
+func SetDefault(s Scanner) {
+	mu.Lock()
+	current = s
+	mu.Unlock()
+}
+
+// ScanFile analiza un archivo en disco con el antivirus configurado
+func ScanFile(ctx context.Context, path string) (Result, error) {
+	mu.RLock()
+	s := current
+	mu.RUnlock()
+	if s == nil {
+		return Result{}, ErrNotConfigured
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+	return s.Scan(ctx, file)
+}
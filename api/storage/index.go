@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"file-server-sofmar/config"
+	"file-server-sofmar/models"
+	"file-server-sofmar/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// clientIndex mantiene en memoria la metadata de todos los archivos de un cliente
+type clientIndex struct {
+	storagePath string
+	files       map[string]models.FileMetadata // fileID -> metadata
+	dirs        map[string]time.Time           // directorio -> mtime al último escaneo
+}
+
+// fileIndex es el índice de todos los clientes (cliente -> índice)
+type fileIndex struct {
+	mu      sync.RWMutex
+	clients map[string]*clientIndex
+}
+
+var index = &fileIndex{clients: make(map[string]*clientIndex)}
+
+// RebuildIndex recorre el almacenamiento de todos los clientes configurados
+// y reconstruye el índice en memoria. Se llama al iniciar el servidor. Un
+// error en un cliente no impide indexar a los demás: se retorna la cantidad
+// de archivos indexados junto con todos los errores.
+func RebuildIndex() (int, error) {
+	total := 0
+	var errs []error
+	for clientID, clientConfig := range config.ClientConfigs {
+		count, err := RebuildClientIndex(clientID, clientConfig.StoragePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", clientID, err))
+		}
+		total += count
+	}
+	return total, errors.Join(errs...)
+}
+
+// RebuildClientIndex reconstruye el índice de un único cliente con un recorrido
+// completo. Los directorios que no se pueden leer se omiten: el índice queda
+// con el resto de los archivos y se retornan los errores.
+func RebuildClientIndex(clientID, storagePath string) (int, error) {
+	ci := &clientIndex{
+		storagePath: storagePath,
+		files:       make(map[string]models.FileMetadata),
+		dirs:        make(map[string]time.Time),
+	}
+
+	var errs []error
+	root := ClientRoot(storagePath)
+	if _, err := os.Stat(root); err == nil {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				errs = append(errs, err)
+				if info != nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				if path != root && IsHiddenDir(info.Name()) {
+					return filepath.SkipDir
+				}
+				ci.dirs[path] = info.ModTime()
+				return nil
+			}
+			if isPartial(path) {
+				return nil
+			}
+
+			addFile(ci.files, buildMetadata(clientID, storagePath, path, info))
+			return nil
+		})
+	}
+
+	index.mu.Lock()
+	index.clients[clientID] = ci
+	index.mu.Unlock()
+
+	return len(ci.files), errors.Join(errs...)
+}
+
+// addFile agrega un archivo a los encontrados en un recorrido. Los archivos sin
+// metadata toman el fileId del nombre, así que "a/x.pdf" y "b/x.pdf" chocan: se
+// conserva el primero y se registra el conflicto en lugar de pisarlo.
+func addFile(files map[string]models.FileMetadata, metadata models.FileMetadata) {
+	if existing, ok := files[metadata.FileID]; ok && existing.Path != metadata.Path {
+		slog.Warn("fileId duplicado: se ignora el archivo",
+			"client", metadata.Client, "file_id", metadata.FileID, "path", metadata.Path, "indexed", existing.Path)
+		return
+	}
+	files[metadata.FileID] = metadata
+}
+
+// buildMetadata arma la metadata de un archivo a partir del filesystem,
+// completándola con la metadata persistida si existe
+func buildMetadata(clientID, storagePath, path string, info os.FileInfo) models.FileMetadata {
+	root := ClientRoot(storagePath)
+	fileName := info.Name()
+	extension := filepath.Ext(fileName)
+
+	folder, _ := filepath.Rel(root, filepath.Dir(path))
+	if folder == "." {
+		folder = ""
+	}
+
+	metadata := models.FileMetadata{
+		FileID:       FileIDFromName(fileName),
+		OriginalName: fileName,
+		FileName:     fileName,
+		Client:       clientID,
+		Folder:       folder,
+		Size:         info.Size(),
+		MimeType:     MimeTypeFromExtension(extension),
+		Extension:    extension,
+		UploadedAt:   info.ModTime(),
+		URL:          FileURL(clientID, folder, fileName),
+		Path:         path,
+		Thumbnails:   ThumbnailURLs(FileIDFromName(fileName), extension),
+	}
+
+	if stored, err := LoadMetadata(storagePath, metadata.FileID); err == nil {
+		MergeMetadata(&metadata, stored)
+	}
+	return metadata
+}
+
+// getClient retorna el índice de un cliente, construyéndolo si aún no existe
+func getClient(clientID string) (*clientIndex, error) {
+	index.mu.RLock()
+	ci, ok := index.clients[clientID]
+	index.mu.RUnlock()
+	if ok {
+		return ci, nil
+	}
+
+	clientConfig, exists := config.GetClientConfig(clientID)
+	if !exists {
+		return nil, fmt.Errorf("cliente no configurado: %s", clientID)
+	}
+	if _, err := RebuildClientIndex(clientID, clientConfig.StoragePath); err != nil {
+		slog.Warn("índice de cliente incompleto", "client", clientID, "error", err)
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return index.clients[clientID], nil
+}
+
+// ClientFiles retorna una copia de la metadata de todos los archivos de un cliente
+func ClientFiles(ctx context.Context, clientID string) (files []models.FileMetadata, err error) {
+	_, span := tracing.Start(ctx, "storage.ClientFiles", tracing.Client(clientID))
+	defer func() {
+		span.SetAttributes(attribute.Int("files.count", len(files)))
+		tracing.End(span, err)
+	}()
+
+	ci, err := getClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	files = make([]models.FileMetadata, 0, len(ci.files))
+	for _, metadata := range ci.files {
+		files = append(files, metadata)
+	}
+	return files, nil
+}
+
+// LookupFile busca un archivo por su ID.
+// Las entradas cuyo archivo ya no existe en disco se descartan.
+func LookupFile(ctx context.Context, clientID, fileID string) (models.FileMetadata, bool) {
+	_, span := tracing.Start(ctx, "storage.LookupFile", tracing.Client(clientID), tracing.File(fileID))
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	ci, err := getClient(clientID)
+	if err != nil {
+		return models.FileMetadata{}, false
+	}
+
+	index.mu.RLock()
+	metadata, ok := ci.files[fileID]
+	index.mu.RUnlock()
+
+	if !ok {
+		err = fmt.Errorf("archivo no indexado: %s", fileID)
+		return models.FileMetadata{}, false
+	}
+
+	if _, statErr := os.Stat(metadata.Path); os.IsNotExist(statErr) {
+		UnindexFile(clientID, fileID)
+		err = fmt.Errorf("archivo indexado sin contenido en disco: %s", fileID)
+		return models.FileMetadata{}, false
+	}
+	return metadata, true
+}
+
+// IndexFile registra (o actualiza) un archivo en el índice
+func IndexFile(metadata models.FileMetadata) {
+	ci, err := getClient(metadata.Client)
+	if err != nil {
+		return
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	ci.files[metadata.FileID] = metadata
+}
+
+// UnindexFile elimina un archivo del índice
+func UnindexFile(clientID, fileID string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if ci, ok := index.clients[clientID]; ok {
+		delete(ci.files, fileID)
+	}
+}
+
+// FileIDFromName extrae el fileID (UUID) del nombre de un archivo almacenado
+func FileIDFromName(fileName string) string {
+	// Formato esperado: uuid.extension
+	parts := strings.Split(fileName, ".")
+	if len(parts) > 0 && len(parts[0]) == 36 {
+		return parts[0]
+	}
+	// Fallback: usar el nombre completo sin extensión
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
+}
+
+// FileURL construye la URL estática de un archivo, con subcarpeta si existe
+func FileURL(clientID, folder, fileName string) string {
+	if folder != "" {
+		return fmt.Sprintf("/static/%s/%s/%s", clientID, filepath.ToSlash(folder), fileName)
+	}
+	return fmt.Sprintf("/static/%s/%s", clientID, fileName)
+}
+
+// ThumbnailSizes son los tamaños de miniatura disponibles (lado mayor en píxeles)
+var ThumbnailSizes = map[string]int{
+	"small":  128,
+	"medium": 256,
+	"large":  512,
+}
+
+// HasThumbnails indica si se generan miniaturas para una extensión
+func HasThumbnails(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// ThumbnailURLs retorna las URLs de las miniaturas de un archivo por tamaño
+// (nil si el formato no tiene miniaturas)
+func ThumbnailURLs(fileID, ext string) map[string]string {
+	if !HasThumbnails(ext) {
+		return nil
+	}
+	urls := make(map[string]string, len(ThumbnailSizes))
+	for size := range ThumbnailSizes {
+		urls[size] = fmt.Sprintf("/api/files/thumbnail/%s?size=%s", fileID, size)
+	}
+	return urls
+}
+
+// MimeTypeFromExtension obtiene el MIME type de una extensión
+func MimeTypeFromExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	mimeTypes := map[string]string{
+		".pdf":  "application/pdf",
+		".jpg":  "image/jpeg",
+		".jpeg": "image/jpeg",
+		".png":  "image/png",
+		".gif":  "image/gif",
+		".txt":  "text/plain",
+		".doc":  "application/msword",
+		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		".xls":  "application/vnd.ms-excel",
+		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		".zip":  "application/zip",
+		".rar":  "application/x-rar-compressed",
+	}
+
+	if mimeType, exists := mimeTypes[ext]; exists {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"file-server-sofmar/models"
+	"file-server-sofmar/tracing"
+)
+
+// MetaDir es el directorio oculto donde se guarda la metadata de cada archivo
+const MetaDir = ".meta"
+
+// ClientRoot retorna el directorio raíz de almacenamiento de un cliente
+func ClientRoot(storagePath string) string {
+	return filepath.Join("/app", storagePath)
+}
+
+// IsHiddenDir indica si un directorio es interno del servidor (.meta, etc.)
+// y debe omitirse al recorrer el almacenamiento de un cliente
+func IsHiddenDir(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// metadataPath retorna la ruta del archivo JSON de metadata para un fileID
+func metadataPath(storagePath, fileID string) string {
+	return filepath.Join(ClientRoot(storagePath), MetaDir, fileID+".json")
+}
+
+// fileLocks serializa las modificaciones de la metadata de cada archivo. Las
+// entradas se eliminan cuando nadie las usa.
+var fileLocks = struct {
+	sync.Mutex
+	locks map[string]*fileLock
+}{locks: make(map[string]*fileLock)}
+
+type fileLock struct {
+	sync.Mutex
+	refs int
+}
+
+// LockFile bloquea la metadata de un archivo para leerla, modificarla y
+// guardarla sin pisar cambios concurrentes (PATCH de metadata, reanálisis
+// antivirus, borrado). La función retornada libera el bloqueo.
+func LockFile(clientID, fileID string) func() {
+	key := clientID + "/" + fileID
+
+	fileLocks.Lock()
+	lock, ok := fileLocks.locks[key]
+	if !ok {
+		lock = &fileLock{}
+		fileLocks.locks[key] = lock
+	}
+	lock.refs++
+	fileLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		fileLocks.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(fileLocks.locks, key)
+		}
+		fileLocks.Unlock()
+	}
+}
+
+// SaveMetadata persiste la metadata de un archivo como JSON
+func SaveMetadata(ctx context.Context, storagePath string, metadata models.FileMetadata) (err error) {
+	_, span := tracing.Start(ctx, "storage.SaveMetadata", tracing.Client(metadata.Client), tracing.File(metadata.FileID))
+	defer func() { tracing.End(span, err) }()
+
+	if metadata.FileID == "" {
+		return fmt.Errorf("fileId vacío")
+	}
+
+	metaPath := metadataPath(storagePath, metadata.FileID)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Escribir en un archivo temporal y renombrar para que la escritura sea
+	// atómica. El nombre es único para que dos escrituras no compartan el temporal.
+	tmp, err := os.CreateTemp(filepath.Dir(metaPath), metadata.FileID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), metaPath)
+}
+
+// LoadMetadata lee la metadata persistida de un archivo.
+// Retorna os.ErrNotExist si el archivo no tiene metadata guardada (archivos legacy).
+func LoadMetadata(storagePath, fileID string) (*models.FileMetadata, error) {
+	data, err := os.ReadFile(metadataPath(storagePath, fileID))
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata models.FileMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// DeleteMetadata elimina la metadata persistida de un archivo
+func DeleteMetadata(ctx context.Context, storagePath, fileID string) (err error) {
+	_, span := tracing.Start(ctx, "storage.DeleteMetadata", tracing.File(fileID))
+	defer func() { tracing.End(span, err) }()
+
+	err = os.Remove(metadataPath(storagePath, fileID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// MergeMetadata completa la metadata derivada del filesystem con los datos
+// persistidos (nombre original, hash, fecha de subida, metadata embebida)
+func MergeMetadata(fsMeta *models.FileMetadata, stored *models.FileMetadata) {
+	if stored == nil {
+		return
+	}
+	if stored.OriginalName != "" {
+		fsMeta.OriginalName = stored.OriginalName
+	}
+	if stored.Hash != "" {
+		fsMeta.Hash = stored.Hash
+	}
+	if stored.MimeType != "" {
+		fsMeta.MimeType = stored.MimeType
+	}
+	if !stored.UploadedAt.IsZero() {
+		fsMeta.UploadedAt = stored.UploadedAt
+	}
+	fsMeta.Tags = stored.Tags
+	fsMeta.Custom = stored.Custom
+	fsMeta.Extended = stored.Extended
+	fsMeta.Scan = stored.Scan
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"os"
+	"sync"
+)
+
+// partials registra los archivos que se están escribiendo (subidas y copias en
+// curso) para poder borrarlos si el servidor se apaga antes de terminarlos
+var partials = struct {
+	sync.Mutex
+	paths map[string]struct{}
+}{paths: make(map[string]struct{})}
+
+// TrackPartial marca un archivo como escritura en curso. La función retornada
+// lo desmarca y debe llamarse cuando el archivo quedó completo o fue eliminado.
+func TrackPartial(path string) func() {
+	partials.Lock()
+	partials.paths[path] = struct{}{}
+	partials.Unlock()
+
+	return func() {
+		partials.Lock()
+		delete(partials.paths, path)
+		partials.Unlock()
+	}
+}
+
+// isPartial indica si un archivo se está escribiendo: el índice no lo registra
+// hasta que la subida o copia termine
+func isPartial(path string) bool {
+	partials.Lock()
+	defer partials.Unlock()
+	_, ok := partials.paths[path]
+	return ok
+}
+
+// RemovePartials borra los archivos que siguen a medio escribir y retorna cuántos eliminó
+func RemovePartials() int {
+	partials.Lock()
+	defer partials.Unlock()
+
+	removed := 0
+	for path := range partials.paths {
+		if err := os.Remove(path); err == nil {
+			removed++
+		}
+		delete(partials.paths, path)
+	}
+	return removed
+}
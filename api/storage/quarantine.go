@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"file-server-sofmar/models"
+	"file-server-sofmar/tracing"
+)
+
+// QuarantineDir es el directorio oculto donde se mueven los archivos con
+// malware. No se indexa ni se sirve: solo se accede desde el servidor.
+const QuarantineDir = ".quarantine"
+
+// Quarantine mueve un archivo a la cuarentena del cliente junto con su
+// metadata (<fileId>.json) y borra la metadata original. Quien llama debe
+// sacarlo del índice.
+func Quarantine(ctx context.Context, storagePath string, metadata models.FileMetadata) (err error) {
+	_, span := tracing.Start(ctx, "storage.Quarantine", tracing.Client(metadata.Client), tracing.File(metadata.FileID))
+	defer func() { tracing.End(span, err) }()
+
+	// Solo el usuario del servidor puede entrar al directorio
+	dir := filepath.Join(ClientRoot(storagePath), QuarantineDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	quarantined := filepath.Join(dir, metadata.FileName)
+	if err := os.Rename(metadata.Path, quarantined); err != nil {
+		return err
+	}
+	metadata.Path = quarantined
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, metadata.FileID+".json"), data, 0600); err != nil {
+		return err
+	}
+	return DeleteMetadata(ctx, storagePath, metadata.FileID)
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// StagingDir es el directorio oculto donde se escriben las subidas y copias
+// hasta que el antivirus las aprueba. No se indexa ni se sirve, así que un
+// archivo sin analizar nunca queda accesible en su carpeta final.
+const StagingDir = ".staging"
+
+// StagingPath retorna la ruta donde escribir un archivo nuevo del cliente
+// antes de publicarlo con Publish
+func StagingPath(storagePath, fileName string) (string, error) {
+	// Solo el usuario del servidor puede entrar al directorio
+	dir := filepath.Join(ClientRoot(storagePath), StagingDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+// Publish mueve un archivo de staging a su ubicación final
+func Publish(stagedPath, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.Rename(stagedPath, path)
+}
+
+// RemoveStaging borra los archivos que quedaron en staging porque el servidor
+// se cortó antes de terminar la subida
+func RemoveStaging(storagePath string) error {
+	return os.RemoveAll(filepath.Join(ClientRoot(storagePath), StagingDir))
+}
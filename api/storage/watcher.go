@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"file-server-sofmar/models"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// eventDelay agrupa los eventos de fsnotify: una copia grande genera muchos
+// eventos de escritura y el directorio se reescanea una sola vez al terminar
+const eventDelay = 500 * time.Millisecond
+
+// WatchIndex mantiene el índice sincronizado con cambios hechos fuera de la API
+// (copias manuales, restauración de backups, etc.). Los cambios se detectan con
+// fsnotify (inotify en Linux) y se reescanea solo el directorio afectado.
+// Además, cada intervalo se compara el mtime de los directorios indexados:
+// cubre los eventos perdidos (desborde de la cola de inotify) y los volúmenes
+// de red donde inotify no informa cambios. Si fsnotify no está disponible solo
+// se usa ese sondeo.
+func WatchIndex(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("fsnotify no disponible, el índice se actualiza por sondeo", "interval", interval, "error", err)
+	} else {
+		defer watcher.Close()
+	}
+	watched := make(map[string]bool)
+	syncWatches(watcher, watched)
+
+	// Canales nil (sin fsnotify) nunca reciben: el select queda solo con el sondeo
+	var events chan fsnotify.Event
+	var errs chan error
+	if watcher != nil {
+		events, errs = watcher.Events, watcher.Errors
+	}
+	dirty := make(map[string]bool)
+	flush := time.NewTimer(eventDelay)
+	flush.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case event := <-events:
+			if isInternalPath(event.Name) {
+				continue
+			}
+			dirty[filepath.Dir(event.Name)] = true
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				dirty[event.Name] = true // por si era un directorio
+			}
+			flush.Reset(eventDelay)
+		case err := <-errs:
+			// Cola desbordada: el próximo sondeo recupera lo perdido
+			slog.Warn("error de fsnotify", "error", err)
+		case <-flush.C:
+			for dir := range dirty {
+				if err := syncDir(dir); err != nil {
+					slog.Error("error actualizando índice", "dir", dir, "error", err)
+				}
+				delete(dirty, dir)
+			}
+			syncWatches(watcher, watched)
+		case <-ticker.C:
+			index.mu.RLock()
+			clientIDs := make([]string, 0, len(index.clients))
+			for clientID := range index.clients {
+				clientIDs = append(clientIDs, clientID)
+			}
+			index.mu.RUnlock()
+
+			for _, clientID := range clientIDs {
+				if err := refreshClient(clientID); err != nil {
+					slog.Error("error actualizando índice", "client", clientID, "error", err)
+				}
+			}
+			syncWatches(watcher, watched)
+		}
+	}
+}
+
+// syncWatches agrega a fsnotify los directorios indexados que aún no se observan
+// y deja de observar los que salieron del índice
+func syncWatches(watcher *fsnotify.Watcher, watched map[string]bool) {
+	if watcher == nil {
+		return
+	}
+
+	current := make(map[string]bool)
+	index.mu.RLock()
+	for _, ci := range index.clients {
+		for dir := range ci.dirs {
+			current[dir] = true
+		}
+	}
+	index.mu.RUnlock()
+
+	for dir := range current {
+		if !watched[dir] {
+			if err := watcher.Add(dir); err != nil {
+				slog.Warn("no se puede observar el directorio", "dir", dir, "error", err)
+				continue
+			}
+			watched[dir] = true
+		}
+	}
+	for dir := range watched {
+		if !current[dir] {
+			watcher.Remove(dir) // falla si el directorio ya no existe
+			delete(watched, dir)
+		}
+	}
+}
+
+// syncDir reescanea un directorio tras un evento de fsnotify, o lo quita del
+// índice si fue eliminado
+func syncDir(dir string) error {
+	clientID, ci := clientForPath(dir)
+	if ci == nil {
+		return nil
+	}
+
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		removeDir(ci, dir)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	// Un directorio nuevo se indexa desde el primer directorio conocido hacia arriba
+	index.mu.RLock()
+	_, known := ci.dirs[dir]
+	index.mu.RUnlock()
+	if !known {
+		return syncDir(filepath.Dir(dir))
+	}
+	return rescanDir(clientID, ci, dir, info.ModTime())
+}
+
+// clientForPath retorna el cliente a cuyo almacenamiento pertenece una ruta
+func clientForPath(path string) (string, *clientIndex) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	for clientID, ci := range index.clients {
+		root := ClientRoot(ci.storagePath)
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return clientID, ci
+		}
+	}
+	return "", nil
+}
+
+// isInternalPath indica si una ruta está dentro de un directorio interno (.meta,
+// .thumbs, etc.) o es uno de ellos
+func isInternalPath(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if IsHiddenDir(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshClient detecta directorios modificados o eliminados de un cliente y los reescanea
+func refreshClient(clientID string) error {
+	index.mu.RLock()
+	ci, ok := index.clients[clientID]
+	if !ok {
+		index.mu.RUnlock()
+		return nil
+	}
+	storagePath := ci.storagePath
+	dirs := make(map[string]time.Time, len(ci.dirs))
+	for dir, mtime := range ci.dirs {
+		dirs[dir] = mtime
+	}
+	index.mu.RUnlock()
+
+	// El directorio raíz puede no haber existido al construir el índice
+	root := ClientRoot(storagePath)
+	if _, known := dirs[root]; !known {
+		if _, err := os.Stat(root); err == nil {
+			dirs[root] = time.Time{}
+		}
+	}
+
+	for dir, mtime := range dirs {
+		info, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			removeDir(ci, dir)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if !info.ModTime().Equal(mtime) {
+			if err := rescanDir(clientID, ci, dir, info.ModTime()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rescanDir sincroniza los archivos directos de un directorio y agrega subdirectorios nuevos
+func rescanDir(clientID string, ci *clientIndex, dir string, mtime time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]models.FileMetadata)
+	newDirs := make(map[string]time.Time)
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if entry.IsDir() {
+			if IsHiddenDir(entry.Name()) {
+				continue
+			}
+			index.mu.RLock()
+			_, known := ci.dirs[path]
+			index.mu.RUnlock()
+			if !known {
+				// Subdirectorio nuevo: indexarlo completo
+				if err := walkDir(clientID, ci.storagePath, path, found, newDirs); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if isPartial(path) {
+			continue
+		}
+		addFile(found, buildMetadata(clientID, ci.storagePath, path, info))
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	// Eliminar archivos de este directorio que ya no existen. ReadDir corrió sin
+	// el lock: un archivo que la API indexó mientras tanto no está en found pero
+	// sí en disco, así que se confirma con Stat antes de borrarlo.
+	for fileID, metadata := range ci.files {
+		if filepath.Dir(metadata.Path) == dir {
+			if _, ok := found[fileID]; !ok {
+				if _, err := os.Stat(metadata.Path); os.IsNotExist(err) {
+					delete(ci.files, fileID)
+				}
+			}
+		}
+	}
+
+	// Agregar o actualizar archivos. Las entradas registradas por la API se
+	// conservan mientras el archivo no cambie de tamaño.
+	for fileID, metadata := range found {
+		existing, ok := ci.files[fileID]
+		if ok && existing.Path == metadata.Path && existing.Size == metadata.Size {
+			continue
+		}
+		if ok && existing.Path != metadata.Path {
+			// Mismo fileId en otra ruta: si la anterior sigue existiendo es un
+			// conflicto de nombres (ver addFile), si no el archivo se movió
+			if _, err := os.Stat(existing.Path); err == nil {
+				slog.Warn("fileId duplicado: se ignora el archivo",
+					"client", clientID, "file_id", fileID, "path", metadata.Path, "indexed", existing.Path)
+				continue
+			}
+		}
+		ci.files[fileID] = metadata
+	}
+
+	for path, dirMtime := range newDirs {
+		ci.dirs[path] = dirMtime
+	}
+	ci.dirs[dir] = mtime
+	return nil
+}
+
+// walkDir recorre un subárbol nuevo acumulando sus archivos y directorios
+func walkDir(clientID, storagePath, start string, files map[string]models.FileMetadata, dirs map[string]time.Time) error {
+	return filepath.Walk(start, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if IsHiddenDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			dirs[path] = info.ModTime()
+			return nil
+		}
+		if isPartial(path) {
+			return nil
+		}
+		addFile(files, buildMetadata(clientID, storagePath, path, info))
+		return nil
+	})
+}
+
+// removeDir quita del índice un directorio eliminado y todo su contenido
+func removeDir(ci *clientIndex, dir string) {
+	prefix := dir + string(filepath.Separator)
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	for path := range ci.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			delete(ci.dirs, path)
+		}
+	}
+	for fileID, metadata := range ci.files {
+		if strings.HasPrefix(metadata.Path, prefix) {
+			delete(ci.files, fileID)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package thumbnail
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"file-server-sofmar/imaging"
+	"file-server-sofmar/models"
+	"file-server-sofmar/storage"
+)
+
+// Dir es el directorio oculto donde se guardan las miniaturas de cada archivo
+const Dir = ".thumbs"
+
+// jpegQuality es la calidad de las miniaturas JPEG
+const jpegQuality = 82
+
+// generating evita generar dos veces las miniaturas de un archivo a la vez
+var generating sync.Map // fileID -> *sync.Mutex
+
+// thumbPath retorna la ruta de una miniatura; ext es ".jpg" o ".png"
+func thumbPath(storagePath, fileID, size, ext string) string {
+	return filepath.Join(storage.ClientRoot(storagePath), Dir, fileID+"_"+size+ext)
+}
+
+// existing retorna la miniatura ya generada de un tamaño, si existe
+func existing(storagePath, fileID, size string) (string, string, bool) {
+	for ext, mimeType := range map[string]string{".jpg": "image/jpeg", ".png": "image/png"} {
+		path := thumbPath(storagePath, fileID, size, ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, mimeType, true
+		}
+	}
+	return "", "", false
+}
+
+// Generate genera las miniaturas de todos los tamaños de una imagen. Los
+// formatos sin miniatura se ignoran sin error.
+func Generate(storagePath string, metadata models.FileMetadata) error {
+	if !storage.HasThumbnails(metadata.Extension) {
+		return nil
+	}
+
+	lock, _ := generating.LoadOrStore(metadata.FileID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer func() {
+		lock.(*sync.Mutex).Unlock()
+		generating.Delete(metadata.FileID)
+	}()
+
+	src, _, err := imaging.Decode(metadata.Path)
+	if err != nil {
+		return err
+	}
+
+	for size, maxSide := range storage.ThumbnailSizes {
+		if err := write(storagePath, metadata.FileID, size, imaging.Fit(src, maxSide)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get retorna la ruta y el tipo MIME de una miniatura. Si todavía no existe
+// (archivos legacy o generación pendiente) se genera en el momento.
+func Get(storagePath string, metadata models.FileMetadata, size string) (string, string, error) {
+	if _, ok := storage.ThumbnailSizes[size]; !ok {
+		return "", "", fmt.Errorf("tamaño de miniatura inválido: %s", size)
+	}
+	if path, mimeType, ok := existing(storagePath, metadata.FileID, size); ok {
+		return path, mimeType, nil
+	}
+
+	if err := Generate(storagePath, metadata); err != nil {
+		return "", "", err
+	}
+	if path, mimeType, ok := existing(storagePath, metadata.FileID, size); ok {
+		return path, mimeType, nil
+	}
+	return "", "", fmt.Errorf("el archivo no tiene miniaturas")
+}
+
+// Remove borra todas las miniaturas de un archivo
+func Remove(storagePath, fileID string) {
+	for size := range storage.ThumbnailSizes {
+		os.Remove(thumbPath(storagePath, fileID, size, ".jpg"))
+		os.Remove(thumbPath(storagePath, fileID, size, ".png"))
+	}
+}
+
+// write guarda una miniatura como JPEG, o PNG si tiene transparencias. Se
+// escribe en un temporal y se renombra para no servir miniaturas a medias.
+func write(storagePath, fileID, size string, img *image.RGBA) error {
+	ext := ".jpg"
+	if !img.Opaque() {
+		ext = ".png"
+	}
+	path := thumbPath(storagePath, fileID, size, ext)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), fileID+"_*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if ext == ".png" {
+		err = png.Encode(tmp, img)
+	} else {
+		err = jpeg.Encode(tmp, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,172 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modos de autenticación con certificado de cliente (mTLS)
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// Reloader mantiene el certificado del servidor y la CA de clientes en memoria
+// y los vuelve a leer cuando cambian los archivos (renovación de certificados
+// sin reiniciar el servidor)
+type Reloader struct {
+	certFile      string
+	keyFile       string
+	clientCAFiles []string
+	clientAuth    tls.ClientAuthType
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// New crea la configuración TLS del servidor con HTTP/2 habilitado. clientAuth
+// es none, optional (se verifica si el cliente envía certificado) o require.
+// clientCAFiles son los bundles PEM con los que se verifican los certificados de cliente.
+func New(certFile, keyFile string, clientCAFiles []string, clientAuth string) (*tls.Config, *Reloader, error) {
+	r := &Reloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		clientCAFiles: clientCAFiles,
+		modTimes:      make(map[string]time.Time),
+	}
+
+	switch strings.ToLower(clientAuth) {
+	case "", ClientAuthNone:
+		r.clientAuth = tls.NoClientCert
+	case ClientAuthOptional:
+		r.clientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		r.clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, nil, fmt.Errorf("modo de autenticación TLS de cliente inválido: %s", clientAuth)
+	}
+	if r.clientAuth != tls.NoClientCert && len(clientCAFiles) == 0 {
+		return nil, nil, fmt.Errorf("TLS_CLIENT_CA_FILE es requerido para autenticación mTLS")
+	}
+
+	if err := r.load(); err != nil {
+		return nil, nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		},
+		// Se arma una configuración por conexión para usar siempre la CA vigente
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.connectionConfig(), nil
+		},
+	}
+	return config, r, nil
+}
+
+// connectionConfig retorna la configuración TLS con el certificado y la CA actuales
+func (r *Reloader) connectionConfig() *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{*r.cert},
+		ClientAuth:   r.clientAuth,
+		ClientCAs:    r.clientCAs,
+	}
+}
+
+// load lee el certificado, la clave y la CA de clientes desde disco
+func (r *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("error cargando certificado TLS: %v", err)
+	}
+
+	var clientCAs *x509.CertPool
+	for _, caFile := range r.clientCAFiles {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("error leyendo CA de clientes: %v", err)
+		}
+		if clientCAs == nil {
+			clientCAs = x509.NewCertPool()
+		}
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA de clientes sin certificados válidos: %s", caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	for _, path := range r.files() {
+		if info, err := os.Stat(path); err == nil {
+			r.modTimes[path] = info.ModTime()
+		}
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// files retorna los archivos que se vigilan
+func (r *Reloader) files() []string {
+	return append([]string{r.certFile, r.keyFile}, r.clientCAFiles...)
+}
+
+// changed indica si algún archivo cambió desde la última carga
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch revisa cada intervalo si los archivos cambiaron y los recarga. Si la
+// recarga falla (por ejemplo, certificado y clave copiados a medias) se sigue
+// usando el certificado anterior y se reintenta en el próximo ciclo.
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				slog.Error("error recargando certificados TLS", "error", err)
+				continue
+			}
+			slog.Info("certificados TLS recargados", "cert", r.certFile)
+		}
+	}
+}